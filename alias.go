@@ -0,0 +1,67 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Alias generates the First Entry of a new Data Store Chain in appNamespace
+// that references the existing DBI of the Data Store described by m, so that
+// the same data appears in another Namespace without being uploaded again.
+//
+// The appMetadata replaces any AppMetadata of m. Pass m.AppMetadata to keep
+// it.
+//
+// The returned Generated only includes the single commit and reveal for the
+// new First Entry, so its TotalCost is only that of creating the new chain.
+func Alias(es factom.EsAddress, m Metadata,
+	appMetadata json.RawMessage, appNamespace ...factom.Bytes) (
+	Generated, error) {
+	if m.DataHash == nil || m.DBIStart == nil {
+		return Generated{}, fmt.Errorf("incomplete Metadata")
+	}
+
+	nameIDs := NameIDs(m.DataHash, appNamespace...)
+	g := Generated{ChainID: factom.ComputeChainID(nameIDs)}
+	if m.Entry.ChainID != nil && *m.Entry.ChainID == g.ChainID {
+		return Generated{}, fmt.Errorf("alias must use a different Namespace")
+	}
+
+	aliasM := Metadata{
+		Version:     Version,
+		Size:        m.Size,
+		Compression: m.Compression,
+		DBIStart:    m.DBIStart,
+		AppMetadata: appMetadata,
+	}
+
+	firstE := factom.Entry{
+		ChainID: &g.ChainID,
+		ExtIDs:  nameIDs,
+	}
+	var err error
+	firstE.Content, err = json.Marshal(aliasM)
+	if err != nil {
+		return Generated{}, err
+	}
+
+	reveal, err := firstE.MarshalBinary()
+	if err != nil {
+		return Generated{}, err
+	}
+	hash := factom.ComputeEntryHash(reveal)
+	commit, txID := factom.GenerateCommit(es, reveal, &hash, true)
+	cost, err := factom.EntryCost(len(reveal), true)
+	if err != nil {
+		return Generated{}, err
+	}
+
+	g.TxIDs = []factom.Bytes32{txID}
+	g.EntryHashes = []factom.Bytes32{hash}
+	g.Commits = []factom.Bytes{commit}
+	g.Reveals = []factom.Bytes{reveal}
+	g.TotalCost = uint(cost)
+	return g, nil
+}
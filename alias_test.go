@@ -0,0 +1,55 @@
+package datastore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestAlias(t *testing.T) {
+	require := require.New(t)
+
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	data, m, reveals := generateTestStore(t, 3*factom.EntryMaxDataLen, "gzip",
+		factom.Bytes("original"))
+	factomd.AddEBlock(reveals...)
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+
+	_, err = Alias(es, m, nil, factom.Bytes("original"))
+	require.Error(err, "same namespace")
+
+	g, err := Alias(es, m, []byte(`{"alias":true}`), factom.Bytes("alias"))
+	require.NoError(err)
+	require.Len(g.TxIDs, 1)
+	require.Len(g.EntryHashes, 1)
+	require.Len(g.Commits, 1)
+	require.Len(g.Reveals, 1)
+	expected, _ := factom.EntryCost(len(g.Reveals[0]), true)
+	assert.Equal(t, uint(expected), g.TotalCost)
+	assert.Equal(t, factom.ComputeChainID(
+		NameIDs(m.DataHash, factom.Bytes("alias"))), g.ChainID)
+
+	factomd.AddEBlock(g.Reveals...)
+
+	alias, err := Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+	assert.Equal(t, *m.DBIStart, *alias.DBIStart)
+	assert.Equal(t, *m.DataHash, *alias.DataHash)
+	assert.Equal(t, *m.Compression, *alias.Compression)
+	assert.JSONEq(t, `{"alias":true}`, string(alias.AppMetadata))
+	assert.Equal(t, []factom.Bytes{factom.Bytes("alias")}, alias.Namespace())
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(alias.Download(nil, c, buf))
+	assert.Equal(t, data, buf.Bytes())
+}
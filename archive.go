@@ -0,0 +1,334 @@
+package datastore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"golang.org/x/sync/errgroup"
+)
+
+// ArchiveMagic begins every serialized Archive.
+const ArchiveMagic = "fds-archive 1.0\n"
+
+// Archive holds the raw Entries of a Data Store, so that it may be stored
+// offline, moved between networks, and verified without factomd.
+//
+// An Archive is serialized as ArchiveMagic, followed by the big endian uint32
+// number of Entries, and then each raw Entry prefixed by its big endian uint16
+// length.
+type Archive struct {
+	// Metadata parsed from the First Entry.
+	Metadata
+
+	// Reveals are the raw First Entry, the DBI Entries in linked list
+	// order, and the unique Data Block Entries in DBI order.
+	Reveals []factom.Bytes
+}
+
+// Export downloads all Entries of the Data Store with the given chainID into
+// an Archive.
+//
+// This is equivalent to DownloadOptions{}.Export(ctx, c, chainID).
+func Export(ctx context.Context, c *factom.Client,
+	chainID *factom.Bytes32) (Archive, error) {
+	return DownloadOptions{}.Export(ctx, c, chainID)
+}
+
+// Export downloads all Entries of the Data Store with the given chainID into
+// an Archive, which is then verified as with Import.
+//
+// All Entries are held in memory.
+func (opts DownloadOptions) Export(ctx context.Context, c *factom.Client,
+	chainID *factom.Bytes32) (Archive, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var m Metadata
+	var err error
+	if opts.Pool != nil {
+		m, err = opts.Pool.Lookup(ctx, ParseOptions{}, chainID)
+	} else {
+		m, err = Lookup(ctx, c, chainID)
+	}
+	if err != nil {
+		return Archive{}, err
+	}
+	first, err := m.Entry.MarshalBinary()
+	if err != nil {
+		return Archive{}, err
+	}
+	a := Archive{Metadata: m, Reveals: []factom.Bytes{first}}
+
+	// Download the DBI Entries. The DBI is fully validated by
+	// verifyArchive, below.
+	dbiEs, dbi, err := opts.getDBIEntries(ctx, c, m)
+	if err != nil {
+		return Archive{}, err
+	}
+	for _, dbiE := range dbiEs {
+		data, err := dbiE.MarshalBinary()
+		if err != nil {
+			return Archive{}, err
+		}
+		a.Reveals = append(a.Reveals, data)
+	}
+
+	// Download the unique Data Block Entries.
+	size := m.Size
+	if m.Compression != nil {
+		size = m.Compression.Size
+	}
+	index := make(map[factom.Bytes32]int, len(dbi))
+	dbEs := make(chan dataBlock, len(dbi))
+	for i := range dbi {
+		if _, ok := index[dbi[i]]; ok {
+			continue
+		}
+		index[dbi[i]] = len(index)
+		dbELen := size - uint64(i)*factom.EntryMaxDataLen
+		if size < uint64(i)*factom.EntryMaxDataLen {
+			dbELen = 0
+		} else if dbELen > factom.EntryMaxDataLen {
+			dbELen = factom.EntryMaxDataLen
+		}
+		dbE := dataBlock{I: i}
+		dbE.Hash = &dbi[i]
+		dbE.Content = make([]byte, 0, dbELen)
+		dbEs <- dbE
+	}
+	close(dbEs)
+	dbReveals := make([]factom.Bytes, len(index))
+	var mu sync.Mutex
+	g, gCtx := errgroup.WithContext(ctx)
+	opts.getDataBlocks(g, gCtx, c, dbEs, newProgress(opts.Progress,
+		StageDownload, len(index), 0),
+		func(dbE dataBlock) error {
+			data, err := dbE.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			dbReveals[index[*dbE.Hash]] = data
+			return nil
+		})
+	if err := g.Wait(); err != nil {
+		return Archive{}, err
+	}
+	a.Reveals = append(a.Reveals, dbReveals...)
+
+	if _, err := verifyArchive(a.Reveals); err != nil {
+		return Archive{}, err
+	}
+	return a, nil
+}
+
+// getDBIEntries downloads the DBI Entries of m by following the linked list,
+// and returns them along with the Data Block Entry Hashes that they contain.
+//
+// Unlike traverseDBI, the DBI is not validated, other than to limit the
+// number of DBI Entries.
+func (opts DownloadOptions) getDBIEntries(ctx context.Context, c *factom.Client,
+	m Metadata) ([]factom.Entry, []factom.Bytes32, error) {
+	if m.IsProof() {
+		return nil, nil, ErrNoData
+	}
+	dbiECount, _ := m.EntryCounts()
+	dbiEs := make([]factom.Entry, 0, dbiECount)
+	var dbi []factom.Bytes32
+	dbiEHash := *m.DBIStart
+	for len(dbiEs) < dbiECount {
+		hash := dbiEHash
+		dbiE := factom.Entry{Hash: &hash}
+		if err := opts.getEntry(ctx, c, &dbiE); err != nil {
+			return nil, nil, err
+		}
+		dbiEs = append(dbiEs, dbiE)
+		for i := 0; i+32 <= len(dbiE.Content); i += 32 {
+			var dbEHash factom.Bytes32
+			copy(dbEHash[:], dbiE.Content[i:])
+			dbi = append(dbi, dbEHash)
+		}
+		if len(dbiE.ExtIDs) != 1 || len(dbiE.ExtIDs[0]) != 32 {
+			break
+		}
+		copy(dbiEHash[:], dbiE.ExtIDs[0])
+	}
+	return dbiEs, dbi, nil
+}
+
+// getEntryHashes returns the Entry Hashes of the First Entry, the DBI Entries,
+// and the unique Data Block Entries of m, in the same order as
+// Archive.Reveals, along with the Chain ID of each.
+//
+// The Data Blocks are not downloaded, so each is given the Chain ID of the DBI
+// Entry that first references it, which is only where Generate places it,
+// unless it is a reused Known Data Block.
+func (opts DownloadOptions) getEntryHashes(ctx context.Context,
+	c *factom.Client, m Metadata) (hashes, chainIDs []factom.Bytes32,
+	err error) {
+	dbiEs, dbi, err := opts.getDBIEntries(ctx, c, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes = make([]factom.Bytes32, 0, 1+len(dbiEs)+len(dbi))
+	chainIDs = make([]factom.Bytes32, 0, cap(hashes))
+	hashes = append(hashes, *m.Entry.Hash)
+	chainIDs = append(chainIDs, *m.Entry.ChainID)
+	for _, dbiE := range dbiEs {
+		hashes = append(hashes, *dbiE.Hash)
+		chainIDs = append(chainIDs, *dbiE.ChainID)
+	}
+	seen := make(map[factom.Bytes32]struct{}, len(dbi))
+	var dbiE, n int
+	for _, dbEHash := range dbi {
+		// Find the DBI Entry that holds this hash.
+		for n == len(dbiEs[dbiE].Content)/32 {
+			dbiE++
+			n = 0
+		}
+		n++
+		if _, ok := seen[dbEHash]; ok {
+			continue
+		}
+		seen[dbEHash] = struct{}{}
+		hashes = append(hashes, dbEHash)
+		chainIDs = append(chainIDs, *dbiEs[dbiE].ChainID)
+	}
+	return hashes, chainIDs, nil
+}
+
+// Import reads a serialized Archive from r, and verifies every Entry Hash, the
+// Data Store structure, and the sha256d data hash, without factomd.
+func Import(r io.Reader) (Archive, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(ArchiveMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return Archive{}, err
+	}
+	if string(magic) != ArchiveMagic {
+		return Archive{}, fmt.Errorf("invalid archive")
+	}
+	var count uint32
+	if err := binary.Read(br, binary.BigEndian, &count); err != nil {
+		return Archive{}, err
+	}
+	if count < 3 {
+		return Archive{}, fmt.Errorf("invalid archive: too few Entries")
+	}
+	a := Archive{Reveals: make([]factom.Bytes, 0, 1024)}
+	for i := uint32(0); i < count; i++ {
+		var l uint16
+		if err := binary.Read(br, binary.BigEndian, &l); err != nil {
+			return Archive{}, err
+		}
+		if int(l) > factom.EntryMaxTotalLen {
+			return Archive{}, fmt.Errorf(
+				"invalid archive: Entry too long")
+		}
+		data := make(factom.Bytes, l)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return Archive{}, err
+		}
+		a.Reveals = append(a.Reveals, data)
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return Archive{}, fmt.Errorf("invalid archive: trailing data")
+	}
+
+	var err error
+	if a.Metadata, err = verifyArchive(a.Reveals); err != nil {
+		return Archive{}, err
+	}
+	return a, nil
+}
+
+// WriteTo writes the serialized Archive to w.
+func (a Archive) WriteTo(w io.Writer) (int64, error) {
+	buf := bytes.NewBufferString(ArchiveMagic)
+	binary.Write(buf, binary.BigEndian, uint32(len(a.Reveals)))
+	for _, data := range a.Reveals {
+		binary.Write(buf, binary.BigEndian, uint16(len(data)))
+		buf.Write(data)
+	}
+	return buf.WriteTo(w)
+}
+
+// Extract writes the data of the Archive to w.
+func (a Archive) Extract(w io.Writer) error {
+	entries, err := archiveEntries(a.Reveals)
+	if err != nil {
+		return err
+	}
+	return DownloadOptions{entries: entries}.Download(nil, nil, a.Metadata, w)
+}
+
+// verifyArchive parses the First Entry of reveals and verifies that the
+// remaining reveals are exactly the Entries of a valid Data Store.
+func verifyArchive(reveals []factom.Bytes) (Metadata, error) {
+	if len(reveals) == 0 {
+		return Metadata{}, fmt.Errorf("invalid archive: no Entries")
+	}
+	var first factom.Entry
+	if err := first.UnmarshalBinary(reveals[0]); err != nil {
+		return Metadata{}, fmt.Errorf("invalid archive: First Entry: %w",
+			err)
+	}
+	if factom.ComputeChainID(first.ExtIDs) != *first.ChainID {
+		return Metadata{}, fmt.Errorf(
+			"invalid archive: invalid First Entry ChainID")
+	}
+	m, err := ParseEntry(first)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	entries, err := archiveEntries(reveals[1:])
+	if err != nil {
+		return Metadata{}, err
+	}
+	opts := DownloadOptions{entries: entries}
+
+	// Ensure that there are no extra Entries.
+	dbi, err := opts.GetDBI(nil, nil, m)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("invalid archive: %w", err)
+	}
+	unique := make(map[factom.Bytes32]struct{}, len(dbi))
+	for _, dbEHash := range dbi {
+		unique[dbEHash] = struct{}{}
+	}
+	dbiECount, _ := m.EntryCounts()
+	if len(entries) != dbiECount+len(unique) {
+		return Metadata{}, fmt.Errorf("invalid archive: extra Entries")
+	}
+
+	// Verify the Data Blocks and the data hash.
+	if err := opts.Download(nil, nil, m, ioutil.Discard); err != nil {
+		return Metadata{}, fmt.Errorf("invalid archive: %w", err)
+	}
+	return m, nil
+}
+
+// archiveEntries returns reveals by Entry Hash, and ensures that there are no
+// duplicates.
+func archiveEntries(reveals []factom.Bytes) (
+	map[factom.Bytes32]factom.Bytes, error) {
+	entries := make(map[factom.Bytes32]factom.Bytes, len(reveals))
+	for _, data := range reveals {
+		hash := factom.ComputeEntryHash(data)
+		if _, ok := entries[hash]; ok {
+			return nil, fmt.Errorf(
+				"invalid archive: duplicate Entry %v", hash)
+		}
+		entries[hash] = data
+	}
+	return entries, nil
+}
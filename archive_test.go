@@ -0,0 +1,109 @@
+package datastore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestArchive(t *testing.T) {
+	require := require.New(t)
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	// Repeated Data Blocks are only archived once.
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	data := make([]byte, 400*factom.EntryMaxDataLen+100)
+	data[len(data)-1] = 1
+	dataHash := ComputeDataHash(data)
+	chainID, _, _, _, reveals, _, err := Generate(nil, nil, es,
+		bytes.NewReader(data), nil, uint64(len(data)), &dataHash, nil)
+	require.NoError(err)
+	factomd.AddEBlock(reveals...)
+
+	a, err := Export(nil, c, &chainID)
+	require.NoError(err)
+	require.Len(a.Reveals, 1+2+2)
+	assert.Equal(t, chainID, *a.Entry.ChainID)
+
+	buf := bytes.NewBuffer(nil)
+	_, err = a.WriteTo(buf)
+	require.NoError(err)
+	serialized := buf.Bytes()
+
+	imported, err := Import(bytes.NewReader(serialized))
+	require.NoError(err)
+	assert.Equal(t, a.Reveals, imported.Reveals)
+	assert.Equal(t, dataHash, *imported.DataHash)
+
+	extracted := bytes.NewBuffer(nil)
+	require.NoError(imported.Extract(extracted))
+	assert.Equal(t, data, extracted.Bytes())
+
+	_, _, extra := generateTestStore(t, 100, "")
+	for _, test := range []struct {
+		Name    string
+		Reveals func() []factom.Bytes
+	}{{
+		Name: "tampered Data Block",
+		Reveals: func() []factom.Bytes {
+			rs := clone(a.Reveals)
+			rs[len(rs)-1][len(rs[len(rs)-1])-1]++
+			return rs
+		},
+	}, {
+		Name: "missing Entry",
+		Reveals: func() []factom.Bytes {
+			return clone(a.Reveals[:len(a.Reveals)-1])
+		},
+	}, {
+		Name: "extra Entry",
+		Reveals: func() []factom.Bytes {
+			return append(clone(a.Reveals), extra[1])
+		},
+	}, {
+		Name: "duplicate Entry",
+		Reveals: func() []factom.Bytes {
+			return append(clone(a.Reveals), a.Reveals[1])
+		},
+	}, {
+		Name: "wrong First Entry",
+		Reveals: func() []factom.Bytes {
+			rs := clone(a.Reveals)
+			rs[0] = extra[0]
+			return rs
+		},
+	}} {
+		t.Run(test.Name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			_, err := Archive{Reveals: test.Reveals()}.WriteTo(buf)
+			require.NoError(err)
+			_, err = Import(buf)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Import(bytes.NewReader(serialized[1:]))
+		assert.EqualError(t, err, "invalid archive")
+		_, err = Import(bytes.NewReader(append(serialized, 0)))
+		assert.EqualError(t, err, "invalid archive: trailing data")
+		_, err = Import(bytes.NewReader(serialized[:len(serialized)-1]))
+		assert.Error(t, err)
+	})
+}
+
+func clone(reveals []factom.Bytes) []factom.Bytes {
+	cloned := make([]factom.Bytes, len(reveals))
+	for i, data := range reveals {
+		cloned[i] = append(factom.Bytes(nil), data...)
+	}
+	return cloned
+}
@@ -0,0 +1,167 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// EntryStatus is the publication status of an Entry, as reported by factomd's
+// "ack" API. See the Status constants.
+type EntryStatus struct {
+	EntryHash factom.Bytes32
+
+	// The status of the Entry's commit, or StatusUnknown if the commit's
+	// Transaction ID is not known.
+	Commit string
+
+	// The status of the Entry's reveal.
+	Reveal string
+}
+
+// Committed returns true if the Entry's commit has been acknowledged.
+func (s EntryStatus) Committed() bool {
+	return s.Commit == StatusTransactionACK || s.Commit == StatusDBlockConfirmed
+}
+
+// Revealed returns true if the Entry has been acknowledged.
+func (s EntryStatus) Revealed() bool {
+	return s.Reveal == StatusTransactionACK || s.Reveal == StatusDBlockConfirmed
+}
+
+// Confirmed returns true if the Entry has been confirmed in a Directory Block.
+func (s EntryStatus) Confirmed() bool {
+	return s.Reveal == StatusDBlockConfirmed
+}
+
+// Audit returns the status of the commit and reveal of each Entry of g, in
+// order.
+func (g Generated) Audit(ctx context.Context,
+	c *factom.Client) ([]EntryStatus, error) {
+	if len(g.TxIDs) != len(g.Reveals) || len(g.EntryHashes) != len(g.Reveals) {
+		return nil, fmt.Errorf("mismatched number of commits and reveals")
+	}
+	statuses := make([]EntryStatus, len(g.Reveals))
+	for i, reveal := range g.Reveals {
+		if len(reveal) < factom.EntryHeaderLen {
+			return nil, fmt.Errorf("reveal %v: invalid reveal", i)
+		}
+		var chainID factom.Bytes32
+		copy(chainID[:], reveal[1:])
+		s, err := auditEntry(ctx, c, &g.EntryHashes[i], &chainID)
+		if err != nil {
+			return nil, fmt.Errorf("reveal %v: %w", i, err)
+		}
+		if !s.Revealed() {
+			if s.Commit, err = AckStatus(ctx, c, &g.TxIDs[i],
+				nil); err != nil {
+				return nil, fmt.Errorf("commit %v: %w", i, err)
+			}
+		}
+		statuses[i] = s
+	}
+	return statuses, nil
+}
+
+// Audit returns the status of the First Entry, the DBI Entries, and the unique
+// Data Block Entries, in DBI order, of the Data Store described by m.
+//
+// Since the DBI must be downloaded to discover the Data Block Entry Hashes,
+// an error is returned if any DBI Entry is missing. The commit status of each
+// Entry is not known, so it is StatusUnknown.
+//
+// Each Entry is acknowledged on its own Chain. A Data Block is assumed to be
+// on the Chain of its DBI Entry, such as a GenerateOptions.PoolChainID, but
+// if it is not acknowledged there, it may be a reused Known Data Block on
+// another Chain, so its Chain ID is read from the Entry, if it exists.
+func (m Metadata) Audit(ctx context.Context,
+	c *factom.Client) ([]EntryStatus, error) {
+	hashes, chainIDs, err := DownloadOptions{}.getEntryHashes(ctx, c, m)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]EntryStatus, len(hashes))
+	for i := range hashes {
+		s, err := auditEntry(ctx, c, &hashes[i], &chainIDs[i])
+		if err != nil {
+			return nil, err
+		}
+		if !s.Revealed() {
+			e := factom.Entry{Hash: &hashes[i]}
+			if err := e.Get(ctx, c); err == nil &&
+				*e.ChainID != chainIDs[i] {
+				if s, err = auditEntry(ctx, c, &hashes[i],
+					e.ChainID); err != nil {
+					return nil, err
+				}
+			}
+		}
+		statuses[i] = s
+	}
+	return statuses, nil
+}
+
+func auditEntry(ctx context.Context, c *factom.Client,
+	hash, chainID *factom.Bytes32) (EntryStatus, error) {
+	s := EntryStatus{EntryHash: *hash, Commit: StatusUnknown}
+	var err error
+	s.Reveal, err = AckStatus(ctx, c, hash, chainID)
+	return s, err
+}
+
+// Repair publishes the Entries of g that are missing using
+// PublishOptions{}.Repair.
+func (g Generated) Repair(ctx context.Context, c *factom.Client,
+	es factom.EsAddress) (Generated, error) {
+	return PublishOptions{}.Repair(ctx, c, es, g)
+}
+
+// Repair audits g, and then publishes only the Entries of g that have not been
+// revealed.
+//
+// The saved commit of a missing Entry is only submitted again if factomd still
+// acknowledges it. Otherwise the commit may have expired, or its timestamp may
+// be too old to be accepted, so a new commit is generated and paid for by es.
+// The First Entry, at index 0, is committed as a new chain.
+//
+// The returned Generated holds any new commits and Transaction IDs, so that it
+// may be saved in place of g.
+func (opts PublishOptions) Repair(ctx context.Context, c *factom.Client,
+	es factom.EsAddress, g Generated) (Generated, error) {
+	statuses, err := g.Audit(ctx, c)
+	if err != nil {
+		return g, err
+	}
+
+	g.TxIDs = append([]factom.Bytes32(nil), g.TxIDs...)
+	g.Commits = append([]factom.Bytes(nil), g.Commits...)
+
+	// Repeated Data Blocks are only published once.
+	var missing Generated
+	seen := make(map[factom.Bytes32]struct{})
+	for i, s := range statuses {
+		if s.Revealed() {
+			continue
+		}
+		if _, ok := seen[s.EntryHash]; ok {
+			continue
+		}
+		seen[s.EntryHash] = struct{}{}
+		if !s.Committed() {
+			g.Commits[i], g.TxIDs[i] = factom.GenerateCommit(es,
+				g.Reveals[i], &g.EntryHashes[i], i == 0)
+		}
+		missing.TxIDs = append(missing.TxIDs, g.TxIDs[i])
+		missing.EntryHashes = append(missing.EntryHashes, g.EntryHashes[i])
+		missing.Commits = append(missing.Commits, g.Commits[i])
+		missing.Reveals = append(missing.Reveals, g.Reveals[i])
+	}
+	if len(missing.Reveals) == 0 {
+		return g, nil
+	}
+
+	return g, opts.Publish(ctx, c, missing.TxIDs, missing.EntryHashes,
+		missing.Commits, missing.Reveals)
+}
@@ -0,0 +1,141 @@
+package datastore
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestAudit(t *testing.T) {
+	require := require.New(t)
+
+	_, _, reveals := generateTestStore(t, 5*factom.EntryMaxDataLen, "",
+		factom.Bytes("test"))
+
+	s := factomdtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	g, err := Replay(es, reveals)
+	require.NoError(err)
+
+	// Publish all commits, but only some reveals, and then let the
+	// remaining commits expire.
+	n := len(g.Reveals)
+	require.NoError(Publish(nil, c, g.TxIDs[:n-2], g.EntryHashes[:n-2],
+		g.Commits[:n-2], g.Reveals[:n-2]))
+	for i := n - 2; i < n; i++ {
+		require.NoError(SubmitCommit(nil, c, g.Commits[i], &g.TxIDs[i]))
+	}
+	s.ExpireCommits()
+	// Commit the last Entry again so that it does not need a new commit.
+	require.NoError(SubmitCommit(nil, c, g.Commits[n-1], &g.TxIDs[n-1]))
+
+	statuses, err := g.Audit(nil, c)
+	require.NoError(err)
+	require.Len(statuses, n)
+	for i, status := range statuses[:n-2] {
+		assert.Equal(t, g.EntryHashes[i], status.EntryHash)
+		assert.True(t, status.Revealed(), i)
+	}
+	assert.False(t, statuses[n-2].Revealed())
+	assert.False(t, statuses[n-2].Committed())
+	assert.False(t, statuses[n-1].Revealed())
+	assert.True(t, statuses[n-1].Committed())
+
+	// The Metadata audit requires the DBI, so it can be done now that the
+	// First Entry and the DBI Entries are confirmed.
+	s.Confirm()
+	m, err := Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+	mStatuses, err := m.Audit(nil, c)
+	require.NoError(err)
+	require.Len(mStatuses, n)
+	var missing []factom.Bytes32
+	for _, status := range mStatuses {
+		if !status.Revealed() {
+			missing = append(missing, status.EntryHash)
+		}
+	}
+	assert.ElementsMatch(t, g.EntryHashes[n-2:], missing)
+
+	// Only the missing Entries are published, and the expired commit is
+	// replaced.
+	repaired, err := g.Repair(nil, c, es)
+	require.NoError(err)
+	assert.Equal(t, g.EntryHashes, repaired.EntryHashes)
+	assert.Equal(t, g.Reveals, repaired.Reveals)
+	assert.Equal(t, g.TxIDs[:n-2], repaired.TxIDs[:n-2])
+	assert.NotEqual(t, g.TxIDs[n-2], repaired.TxIDs[n-2])
+	assert.Equal(t, g.TxIDs[n-1], repaired.TxIDs[n-1])
+
+	s.Confirm()
+	statuses, err = repaired.Audit(nil, c)
+	require.NoError(err)
+	for i, status := range statuses {
+		assert.True(t, status.Confirmed(), i)
+	}
+	require.NoError(repaired.Verify(nil, c))
+
+	// Repairing a complete Data Store does nothing.
+	again, err := repaired.Repair(nil, c, es)
+	require.NoError(err)
+	assert.Equal(t, repaired, again)
+}
+
+func TestAuditChains(t *testing.T) {
+	require := require.New(t)
+
+	s := factomdtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+
+	// A Data Store that reuses a Data Block of another Data Store, and
+	// places its other Entries on a pool Chain.
+	known := make(KnownBlocks)
+	data := make([]byte, 2*factom.EntryMaxDataLen)
+	rand.Read(data)
+	dataHash := ComputeDataHash(data)
+	g, err := GenerateOptions{Known: known}.Generate(nil, nil, es,
+		bytes.NewReader(data), nil, uint64(len(data)), &dataHash, nil)
+	require.NoError(err)
+	require.NoError(g.Publish(nil, c))
+
+	poolE := factom.Entry{ExtIDs: []factom.Bytes{factom.Bytes("pool")}}
+	poolChainID := factom.ComputeChainID(poolE.ExtIDs)
+	poolE.ChainID = &poolChainID
+	pool, err := poolE.MarshalBinary()
+	require.NoError(err)
+	s.AddEBlock(pool)
+
+	data = append(data[:factom.EntryMaxDataLen:factom.EntryMaxDataLen],
+		[]byte("new")...)
+	dataHash = ComputeDataHash(data)
+	g, err = GenerateOptions{Known: known, PoolChainID: &poolChainID}.
+		Generate(nil, nil, es, bytes.NewReader(data), nil,
+			uint64(len(data)), &dataHash, nil)
+	require.NoError(err)
+	require.NotZero(g.SavedCost)
+	require.NoError(g.Publish(nil, c))
+	s.Confirm()
+
+	m, err := Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+	statuses, err := m.Audit(nil, c)
+	require.NoError(err)
+	require.Len(statuses, 4)
+	for i, status := range statuses {
+		assert.True(t, status.Confirmed(), i)
+	}
+}
@@ -0,0 +1,144 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// errBatchUnsupported is returned when factomd does not respond to a batch
+// request with a batch response.
+var errBatchUnsupported = errors.New("batch requests are not supported")
+
+// getEntries populates es by their Hashes using JSON-RPC 2.0 batch requests.
+// Any Entries that could not be populated by the batch request are requested
+// individually with getEntry.
+//
+// If factomd rejects batch requests, batchUnsupported is set, and all future
+// calls request each Entry individually.
+func (opts DownloadOptions) getEntries(ctx context.Context, c *factom.Client,
+	es []*factom.Entry, batchUnsupported *int32) error {
+	// Entry.IsPopulated is false for Entries without ExtIDs, so track
+	// which Entries were populated by the batch request.
+	done := make([]bool, len(es))
+	if len(es) > 1 && opts.entries == nil &&
+		atomic.LoadInt32(batchUnsupported) == 0 {
+		batch := func(c *factom.Client) error {
+			reqCtx := ctx
+			if opts.Timeout > 0 {
+				var cancel context.CancelFunc
+				reqCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+				defer cancel()
+			}
+			return getEntriesBatch(reqCtx, c, es, done)
+		}
+		var err error
+		if opts.Pool != nil {
+			err = opts.Pool.Do(ctx, batch)
+		} else {
+			err = batch(c)
+		}
+		if errors.Is(err, errBatchUnsupported) {
+			atomic.StoreInt32(batchUnsupported, 1)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	for i, e := range es {
+		if done[i] {
+			continue
+		}
+		if err := opts.getEntry(ctx, c, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getEntriesBatch makes a single JSON-RPC 2.0 batch request to factomd for
+// the "raw-data" of each of es, and populates each Entry that is returned and
+// valid, setting done for its index. Entries that are not populated are left
+// with their original Hash and Content.
+//
+// An error is only returned if the batch request as a whole failed.
+func getEntriesBatch(ctx context.Context, c *factom.Client,
+	es []*factom.Entry, done []bool) error {
+	type params struct {
+		Hash *factom.Bytes32 `json:"hash"`
+	}
+	reqs := make(jsonrpc2.BatchRequest, len(es))
+	for i, e := range es {
+		reqs[i] = jsonrpc2.Request{ID: i, Method: "raw-data",
+			Params: params{e.Hash}}
+	}
+	reqBytes, err := json.Marshal(reqs)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.FactomdServer,
+		bytes.NewReader(reqBytes))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.Factomd.Header {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	if c.Factomd.BasicAuth {
+		req.SetBasicAuth(c.Factomd.User, c.Factomd.Password)
+	}
+
+	res, err := c.Factomd.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK &&
+		res.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("http: %v", res.Status)
+	}
+	resBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	// A server that does not support batch requests responds with a
+	// single error Response, or an invalid one.
+	var rawRess []json.RawMessage
+	if json.Unmarshal(resBytes, &rawRess) != nil {
+		return errBatchUnsupported
+	}
+
+	for _, rawRes := range rawRess {
+		var result struct {
+			Data factom.Bytes `json:"data"`
+		}
+		var id int
+		res := jsonrpc2.Response{Result: &result, ID: &id}
+		if json.Unmarshal(rawRes, &res) != nil || res.HasError() ||
+			id < 0 || id >= len(es) || done[id] {
+			continue
+		}
+		// UnmarshalBinary leaves e populated if the data does not
+		// match its Hash, so e must be restored.
+		e := es[id]
+		orig := *e
+		if err := e.UnmarshalBinary(result.Data); err != nil {
+			*e = factom.Entry{Hash: orig.Hash, Content: orig.Content[:0]}
+			continue
+		}
+		done[id] = true
+	}
+	return nil
+}
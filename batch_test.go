@@ -0,0 +1,84 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/AdamSLevy/retry"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestBatch(t *testing.T) {
+	data, m, reveals := generateTestStore(t, 40*factom.EntryMaxDataLen, "")
+	_, dbECount := m.EntryCounts()
+
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	factomd.AddEntries(reveals...)
+	c := factomd.Client()
+
+	download := func(t *testing.T, opts DownloadOptions) int {
+		requests := factomd.Requests()
+		cData, _, err := opts.downloadCData(nil, c, m)
+		require.NoError(t, err)
+		require.Equal(t, data, cData)
+		return factomd.Requests() - requests
+	}
+
+	opts := DownloadOptions{BatchSize: 10, Workers: 1}
+	t.Run("batch", func(t *testing.T) {
+		requests := download(t, opts)
+		// One request for the DBI, and at least one for each batch.
+		assert.Less(t, requests, 1+dbECount)
+		assert.GreaterOrEqual(t, requests, 1+dbECount/opts.BatchSize)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		factomd.RejectBatches(true)
+		defer factomd.RejectBatches(false)
+		requests := download(t, opts)
+		// Only the first batch is attempted.
+		assert.Equal(t, 1+1+dbECount, requests)
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		// Fail every other request within a batch, which must then
+		// be requested individually.
+		rawData := factomd.Method("raw-data")
+		var n int32
+		factomd.Handle("raw-data", func(ctx context.Context,
+			params json.RawMessage) interface{} {
+			if atomic.AddInt32(&n, 1)%2 == 0 {
+				return jsonrpc2.NewError(5, "Temporary Error", nil)
+			}
+			return rawData(ctx, params)
+		})
+		defer factomd.Handle("raw-data", rawData)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, DownloadOptions{BatchSize: 10, Policy: retry.LimitAttempts{
+			Limit: 3, Policy: retry.Immediate{}}}.Download(nil, c, m, buf))
+		require.Equal(t, data, buf.Bytes())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		// Return the wrong Entry for every request within a batch.
+		rawData := factomd.Method("raw-data")
+		wrong, _ := json.Marshal(struct {
+			Hash factom.Bytes32 `json:"hash"`
+		}{factom.ComputeEntryHash(reveals[0])})
+		factomd.Handle("raw-data", func(ctx context.Context,
+			params json.RawMessage) interface{} {
+			return rawData(ctx, wrong)
+		})
+		defer factomd.Handle("raw-data", rawData)
+		assert.Error(t, opts.Download(nil, c, m, bytes.NewBuffer(nil)))
+	})
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+// cacheControl marks responses as cacheable forever, since the data of a Data
+// Store never changes.
+const cacheControl = "public, max-age=31536000, immutable"
+
+// Gateway is an http.Handler that serves Data Stores.
+type Gateway struct {
+	c *factom.Client
+
+	// Options used to download Data Stores.
+	Options datastore.DownloadOptions
+
+	mu        sync.Mutex
+	cache     map[factom.Bytes32]datastore.Metadata
+	cacheSize int
+}
+
+// NewGateway returns a Gateway that uses c to query factomd and caches up to
+// cacheSize Data Store Metadata.
+func NewGateway(c *factom.Client, cacheSize int) *Gateway {
+	return &Gateway{
+		c: c,
+		Options: datastore.DownloadOptions{
+			Policy:    datastore.DefaultDownloadPolicy,
+			BatchSize: datastore.DefaultBatchSize,
+		},
+		cache:     make(map[factom.Bytes32]datastore.Metadata),
+		cacheSize: cacheSize,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (gw *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chainID, err := parsePath(r.URL.EscapedPath())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	m, err := gw.lookup(r.Context(), chainID)
+	if err != nil {
+		var jErr jsonrpc2.Error
+		var netErr net.Error
+		switch {
+		case errors.As(err, &jErr):
+			http.Error(w, "data store not found", http.StatusNotFound)
+		case errors.As(err, &netErr):
+			log.Printf("fds-gateway: lookup %v: %v", chainID, err)
+			http.Error(w, "factomd unavailable", http.StatusBadGateway)
+		default:
+			http.Error(w, "invalid data store: "+err.Error(),
+				http.StatusNotFound)
+		}
+		return
+	}
+	if m.IsProof() {
+		http.Error(w, "data not stored: proof of existence record",
+			http.StatusNotFound)
+		return
+	}
+
+	etag := fmt.Sprintf("%q", m.DataHash)
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", cacheControl)
+	setContentHeaders(h, m.AppMetadata)
+
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	offset, length := uint64(0), m.Size
+	status := http.StatusOK
+	if m.Seekable() {
+		h.Set("Accept-Ranges", "bytes")
+		rng := r.Header.Get("Range")
+		if ifRange := r.Header.Get("If-Range"); ifRange != "" &&
+			ifRange != etag {
+			rng = ""
+		}
+		if rng != "" {
+			var ok bool
+			offset, length, ok, err = parseRange(rng, m.Size)
+			if err != nil {
+				h.Set("Content-Range", fmt.Sprintf("bytes */%v", m.Size))
+				http.Error(w, err.Error(),
+					http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			if ok {
+				status = http.StatusPartialContent
+				h.Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v",
+					offset, offset+length-1, m.Size))
+			}
+		}
+	} else {
+		h.Set("Accept-Ranges", "none")
+	}
+	h.Set("Content-Length", strconv.FormatUint(length, 10))
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+
+	// Defer writing the header until the data is available, so that
+	// errors prior to writing any data can still be reported.
+	tw := &trackingWriter{ResponseWriter: w, status: status}
+	if status == http.StatusPartialContent {
+		err = gw.Options.DownloadRange(r.Context(), gw.c, m, tw,
+			offset, length)
+	} else {
+		err = gw.Options.Download(r.Context(), gw.c, m, tw)
+	}
+	if err != nil {
+		log.Printf("fds-gateway: download %v: %v", chainID, err)
+		if tw.wrote {
+			// The response can no longer be changed, so abort it
+			// to ensure that the client does not receive
+			// incomplete or unverified data as a success.
+			panic(http.ErrAbortHandler)
+		}
+		h.Del("Content-Length")
+		h.Del("Content-Range")
+		h.Del("ETag")
+		h.Del("Cache-Control")
+		http.Error(w, "download failed", http.StatusBadGateway)
+	}
+}
+
+// lookup returns the Metadata for chainID, from the cache if possible. If
+// gw.cacheSize is zero, nothing is cached.
+func (gw *Gateway) lookup(ctx context.Context,
+	chainID *factom.Bytes32) (datastore.Metadata, error) {
+	if gw.cacheSize <= 0 {
+		return gw.get(ctx, chainID)
+	}
+
+	gw.mu.Lock()
+	m, ok := gw.cache[*chainID]
+	gw.mu.Unlock()
+	if ok {
+		return m, nil
+	}
+
+	m, err := gw.get(ctx, chainID)
+	if err != nil {
+		return m, err
+	}
+
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	if len(gw.cache) >= gw.cacheSize {
+		// Evict everything, rather than tracking usage, since
+		// lookups are cheap compared to downloads.
+		gw.cache = make(map[factom.Bytes32]datastore.Metadata)
+	}
+	gw.cache[*chainID] = m
+	return m, nil
+}
+
+// get looks up the Metadata for chainID using gw.Options.Pool, if set, so that
+// lookups fail over to the mirrors as downloads do.
+func (gw *Gateway) get(ctx context.Context,
+	chainID *factom.Bytes32) (datastore.Metadata, error) {
+	if gw.Options.Pool != nil {
+		return gw.Options.Pool.Lookup(ctx, datastore.ParseOptions{},
+			chainID)
+	}
+	return datastore.Lookup(ctx, gw.c, chainID)
+}
+
+// parsePath returns the Chain ID of the Data Store identified by the escaped
+// URL path.
+func parsePath(path string) (*factom.Bytes32, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 2 {
+		return nil, fmt.Errorf("not found")
+	}
+	var hash factom.Bytes32
+	if err := hash.Set(segments[1]); err != nil {
+		return nil, fmt.Errorf("invalid hash: %w", err)
+	}
+	switch segments[0] {
+	case "chain":
+		if len(segments) > 2 {
+			return nil, fmt.Errorf("not found")
+		}
+		return &hash, nil
+	case "hash":
+		namespace := make([]factom.Bytes, len(segments)-2)
+		for i, seg := range segments[2:] {
+			id, err := url.PathUnescape(seg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid namespace: %w", err)
+			}
+			if strings.HasPrefix(id, "0x") {
+				if err := namespace[i].Set(id[2:]); err != nil {
+					return nil, fmt.Errorf(
+						"invalid namespace: %w", err)
+				}
+				continue
+			}
+			namespace[i] = factom.Bytes(id)
+		}
+		chainID := factom.ComputeChainID(
+			datastore.NameIDs(&hash, namespace...))
+		return &chainID, nil
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+// setContentHeaders sets the Content-Type and Content-Disposition headers
+// from the "content-type" and "filename" fields of appMetadata, if it is a
+// JSON object.
+func setContentHeaders(h http.Header, appMetadata json.RawMessage) {
+	var md struct {
+		ContentType string `json:"content-type"`
+		Filename    string `json:"filename"`
+	}
+	json.Unmarshal(appMetadata, &md)
+
+	contentType := md.ContentType
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		contentType = ""
+	}
+	filename := filepath.Base(filepath.Clean("/" + md.Filename))
+	if filename == "/" || filename == "." {
+		filename = ""
+	}
+	if contentType == "" && filename != "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h.Set("Content-Type", contentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	if filename != "" {
+		h.Set("Content-Disposition", mime.FormatMediaType("inline",
+			map[string]string{"filename": filename}))
+	}
+}
+
+// matchETag returns true if the If-None-Match header value matches etag.
+func matchETag(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange parses a Range header value for a resource of the given size. If
+// the header specifies multiple ranges, or a range unit other than bytes, ok
+// is false and the full resource should be served, as RFC 7233 permits. An
+// error is returned if the range is not satisfiable.
+func parseRange(rng string, size uint64) (offset, length uint64, ok bool,
+	err error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(rng, prefix) {
+		return 0, size, false, nil
+	}
+	rng = strings.TrimSpace(rng[len(prefix):])
+	if strings.Contains(rng, ",") {
+		return 0, size, false, nil
+	}
+	dash := strings.Index(rng, "-")
+	if dash < 0 {
+		return 0, 0, false, fmt.Errorf("invalid range")
+	}
+	startStr := strings.TrimSpace(rng[:dash])
+	endStr := strings.TrimSpace(rng[dash+1:])
+
+	if startStr == "" {
+		// A suffix range of the last n bytes.
+		n, err := strconv.ParseUint(endStr, 10, 64)
+		if err != nil || n == 0 {
+			return 0, 0, false, fmt.Errorf("invalid range")
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, true, nil
+	}
+
+	start, err := strconv.ParseUint(startStr, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false, fmt.Errorf("invalid range")
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseUint(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, fmt.Errorf("invalid range")
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, true, nil
+}
+
+// trackingWriter delays writing the status code until the first call to
+// Write, and records whether any data has been written.
+type trackingWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (tw *trackingWriter) Write(data []byte) (int, error) {
+	if !tw.wrote {
+		tw.wrote = true
+		tw.ResponseWriter.WriteHeader(tw.status)
+	}
+	return tw.ResponseWriter.Write(data)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds"
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestParseRange(t *testing.T) {
+	for _, test := range []struct {
+		Range          string
+		Offset, Length uint64
+		OK, Err        bool
+	}{
+		{Range: "bytes=0-9", Offset: 0, Length: 10, OK: true},
+		{Range: "bytes=10-", Offset: 10, Length: 90, OK: true},
+		{Range: "bytes=90-200", Offset: 90, Length: 10, OK: true},
+		{Range: "bytes=-10", Offset: 90, Length: 10, OK: true},
+		{Range: "bytes=-200", Offset: 0, Length: 100, OK: true},
+		{Range: "bytes=0-1,5-6", Offset: 0, Length: 100},
+		{Range: "bytes=100-", Err: true},
+		{Range: "bytes=5-4", Err: true},
+		{Range: "bytes=-0", Err: true},
+		{Range: "items=0-1", Offset: 0, Length: 100},
+	} {
+		offset, length, ok, err := parseRange(test.Range, 100)
+		if test.Err {
+			assert.Error(t, err, test.Range)
+			continue
+		}
+		if assert.NoError(t, err, test.Range) {
+			assert.Equal(t, test.OK, ok, test.Range)
+			assert.Equal(t, test.Offset, offset, test.Range)
+			assert.Equal(t, test.Length, length, test.Range)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	hash := factom.NewBytes32(
+		"1e3fcf78089dd840d132e4f30c1f56072e35cd33e06de879de6e8e859bb00d29")
+
+	chainID, err := parsePath("/chain/" + hash.String())
+	require.NoError(t, err)
+	assert.Equal(t, hash, *chainID)
+
+	chainID, err = parsePath("/hash/" + hash.String() + "/my%2Fapp/0x0102")
+	require.NoError(t, err)
+	assert.Equal(t, factom.ComputeChainID(datastore.NameIDs(&hash,
+		factom.Bytes("my/app"), factom.Bytes{1, 2})), *chainID)
+
+	for _, path := range []string{"/", "/chain", "/chain/xyz",
+		"/chain/" + hash.String() + "/extra", "/other/" + hash.String(),
+		"/hash/" + hash.String() + "/0xzz"} {
+		_, err := parsePath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestGateway(t *testing.T) {
+	require := require.New(t)
+
+	s := factomdtest.NewServer()
+	defer s.Close()
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+
+	// generate a Data Store of data using format, and add its Entries to
+	// s, unless firstOnly is true.
+	generate := func(data []byte, format string,
+		firstOnly bool) datastore.Generated {
+		dataHash := datastore.ComputeDataHash(data)
+		cData, compression, err := datastore.Compress(format, data)
+		require.NoError(err)
+		g, err := datastore.GenerateOptions{}.Generate(nil, nil, es,
+			bytes.NewReader(cData), compression, uint64(len(data)),
+			&dataHash, []byte(`{"filename":"a.txt"}`))
+		require.NoError(err)
+		if firstOnly {
+			s.AddEBlock(g.Reveals[0])
+		} else {
+			s.AddEBlock(g.Reveals...)
+		}
+		return g
+	}
+
+	data := make([]byte, 3*factom.EntryMaxDataLen+10)
+	rand.Read(data)
+	seekable := generate(data, "none", false)
+	compressed := generate(data[1:], "zlib", false)
+	incomplete := generate(data[2:], "none", true)
+	dataHash := datastore.ComputeDataHash(data)
+	etag := `"` + dataHash.String() + `"`
+
+	proofHash := datastore.ComputeDataHash([]byte("proof"))
+	proof, err := datastore.Proof(es, &proofHash, 5, nil)
+	require.NoError(err)
+	s.AddEBlock(proof.Reveals...)
+
+	path := func(chainID factom.Bytes32) string {
+		return "/chain/" + chainID.String()
+	}
+	gw := NewGateway(s.Client(), 0)
+	gw.Options.Policy = nil // Fail missing Entries immediately.
+	for _, test := range []struct {
+		Name    string
+		Method  string
+		Path    string
+		Header  map[string]string
+		Status  int
+		Body    []byte
+		Headers map[string]string
+	}{{
+		Name:   "get",
+		Path:   path(seekable.ChainID),
+		Status: http.StatusOK,
+		Body:   data,
+		Headers: map[string]string{
+			"ETag":           etag,
+			"Content-Type":   "text/plain; charset=utf-8",
+			"Content-Length": "30730",
+			"Accept-Ranges":  "bytes",
+			"Cache-Control":  cacheControl,
+		},
+	}, {
+		Name:   "hash path",
+		Path:   "/hash/" + dataHash.String(),
+		Status: http.StatusOK,
+		Body:   data,
+	}, {
+		Name:    "head",
+		Method:  http.MethodHead,
+		Path:    path(seekable.ChainID),
+		Status:  http.StatusOK,
+		Body:    []byte{},
+		Headers: map[string]string{"Content-Length": "30730"},
+	}, {
+		Name:   "not modified",
+		Path:   path(seekable.ChainID),
+		Header: map[string]string{"If-None-Match": etag},
+		Status: http.StatusNotModified,
+		Body:   []byte{},
+	}, {
+		Name:   "range",
+		Path:   path(seekable.ChainID),
+		Header: map[string]string{"Range": "bytes=10240-10249"},
+		Status: http.StatusPartialContent,
+		Body:   data[10240:10250],
+		Headers: map[string]string{
+			"Content-Range":  "bytes 10240-10249/30730",
+			"Content-Length": "10",
+		},
+	}, {
+		Name: "range with stale If-Range",
+		Path: path(seekable.ChainID),
+		Header: map[string]string{"Range": "bytes=0-9",
+			"If-Range": `"other"`},
+		Status: http.StatusOK,
+		Body:   data,
+	}, {
+		Name:    "unsatisfiable range",
+		Path:    path(seekable.ChainID),
+		Header:  map[string]string{"Range": "bytes=30730-"},
+		Status:  http.StatusRequestedRangeNotSatisfiable,
+		Headers: map[string]string{"Content-Range": "bytes */30730"},
+	}, {
+		Name:   "unknown range unit",
+		Path:   path(seekable.ChainID),
+		Header: map[string]string{"Range": "items=0-9"},
+		Status: http.StatusOK,
+		Body:   data,
+	}, {
+		Name:    "range not seekable",
+		Path:    path(compressed.ChainID),
+		Header:  map[string]string{"Range": "bytes=0-9"},
+		Status:  http.StatusOK,
+		Body:    data[1:],
+		Headers: map[string]string{"Accept-Ranges": "none"},
+	}, {
+		Name:    "method not allowed",
+		Method:  http.MethodPost,
+		Path:    path(seekable.ChainID),
+		Status:  http.StatusMethodNotAllowed,
+		Headers: map[string]string{"Allow": "GET, HEAD"},
+	}, {
+		Name:   "invalid path",
+		Path:   "/chain/xyz",
+		Status: http.StatusNotFound,
+	}, {
+		Name:   "chain not found",
+		Path:   path(factom.Bytes32{1}),
+		Status: http.StatusNotFound,
+	}, {
+		Name:   "proof of existence",
+		Path:   path(proof.ChainID),
+		Status: http.StatusNotFound,
+	}, {
+		Name:    "download failed",
+		Path:    path(incomplete.ChainID),
+		Status:  http.StatusBadGateway,
+		Headers: map[string]string{"ETag": "", "Cache-Control": ""},
+	}} {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			method := test.Method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, test.Path, nil)
+			for key, value := range test.Header {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			gw.ServeHTTP(w, r)
+			res := w.Result()
+			assert.Equal(t, test.Status, res.StatusCode)
+			if test.Body != nil {
+				body, _ := ioutil.ReadAll(res.Body)
+				assert.Equal(t, test.Body, body)
+			}
+			for key, value := range test.Headers {
+				assert.Equal(t, value, res.Header.Get(key), key)
+			}
+		})
+	}
+
+	// Metadata is cached, and only when cacheSize is not zero.
+	calls := s.Calls("chain-head")
+	gw = NewGateway(s.Client(), 10)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, httptest.NewRequest(http.MethodHead,
+			path(seekable.ChainID), nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, calls+1, s.Calls("chain-head"))
+	assert.Empty(t, NewGateway(s.Client(), 0).cache)
+
+	// Lookups and downloads fail over to the mirrors in the Pool, with and
+	// without the cache.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	primary := factom.NewClient()
+	primary.FactomdServer = down.URL
+	for _, cacheSize := range []int{0, 10} {
+		gw = NewGateway(primary, cacheSize)
+		gw.Options.Policy = nil
+		gw.Options.Pool = datastore.NewPool(primary, s.Client())
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+			path(seekable.ChainID), nil))
+		assert.Equal(t, http.StatusOK, w.Code, cacheSize)
+		assert.Equal(t, data, w.Body.Bytes(), cacheSize)
+	}
+
+	// Connection errors are reported as a bad gateway.
+	s.Close()
+	w := httptest.NewRecorder()
+	NewGateway(s.Client(), 0).ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, path(factom.Bytes32{2}), nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
@@ -0,0 +1,70 @@
+// Command fds-gateway serves the data of Factom Data Stores over HTTP.
+//
+// Data Stores are served by Chain ID, or by data hash and Namespace:
+//
+//	GET /chain/<chain id>
+//	GET /hash/<data hash>/<namespace ID>/...
+//
+// Namespace IDs are URL path escaped. IDs prefixed with "0x" are parsed as
+// hex.
+//
+// Since a Data Store is immutable and identified by its data hash, responses
+// use the data hash as a strong ETag and are marked as immutable for caching.
+// The "content-type" and "filename" fields of the application Metadata, if
+// present, are used for the Content-Type and Content-Disposition headers.
+// Range requests are supported for Data Stores without compression or using
+// "gzip-seekable" compression.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+func main() {
+	c := factom.NewClient()
+	listen := flag.String("listen", ":8080", "address to listen on")
+	flag.StringVar(&c.FactomdServer, "factomd", c.FactomdServer,
+		"factomd API endpoint")
+	timeout := flag.Duration("timeout", 10*time.Second,
+		"timeout for each factomd API request")
+	var mirrors mirrorsFlag
+	flag.Var(&mirrors, "mirror",
+		"additional factomd API endpoint to download from, may be repeated")
+	cacheSize := flag.Int("cache", 1000,
+		"maximum number of Data Store Metadata to cache")
+	flag.Parse()
+	c.Factomd.Timeout = *timeout
+
+	gw := NewGateway(c, *cacheSize)
+	if len(mirrors) > 0 {
+		clients := []*factom.Client{c}
+		for _, endpoint := range mirrors {
+			mirror := factom.NewClient()
+			mirror.FactomdServer = endpoint
+			mirror.Factomd.Timeout = *timeout
+			clients = append(clients, mirror)
+		}
+		gw.Options.Pool = datastore.NewPool(clients...)
+	}
+	log.Printf("fds-gateway: listening on %v, using factomd at %v",
+		*listen, c.FactomdServer)
+	log.Fatal(http.ListenAndServe(*listen, gw))
+}
+
+// mirrorsFlag are additional factomd API endpoints.
+type mirrorsFlag []string
+
+func (m *mirrorsFlag) Set(endpoint string) error {
+	*m = append(*m, endpoint)
+	return nil
+}
+
+func (m mirrorsFlag) String() string { return strings.Join(m, ",") }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+const aliasUsage = "[flags] -to <namespace> [-to <namespace>]..."
+
+func alias(ctx context.Context, args []string) error {
+	flags := newFlagSet("alias", aliasUsage)
+	var s storeFlags
+	s.Register(flags)
+	var to Namespace
+	flags.Var(&to, "to",
+		"Namespace ExtID of the alias, may be repeated, prefix with 0x for hex")
+	appMetadata := flags.String("metadata", "",
+		"application defined metadata JSON, defaults to that of the Data Store")
+	yes := flags.Bool("y", false, "publish without asking for confirmation")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("unexpected arguments")
+	}
+
+	m, err := s.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	appMD := m.AppMetadata
+	if len(*appMetadata) > 0 {
+		if appMD, err = parseAppMetadata(*appMetadata); err != nil {
+			return err
+		}
+	}
+
+	es, err := ecEs.GetEsAddress(ctx)
+	if err != nil {
+		return err
+	}
+
+	g := generated{
+		DataHash:    *m.DataHash,
+		Size:        m.Size,
+		Compression: m.Compression,
+	}
+	if g.Generated, err = datastore.Alias(es, m, appMD, to...); err != nil {
+		return err
+	}
+	g.Print()
+
+	if _, err := datastore.Lookup(ctx, c, &g.ChainID); err == nil {
+		fmt.Println("This Data Store already exists.")
+		return nil
+	}
+
+	_, err = publish(ctx, g, *yes)
+	return err
+}
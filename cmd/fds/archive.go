@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/AdamSLevy/retry"
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+const exportUsage = "[flags] (-chainid <chain id> | -hash <data hash>) <archive>"
+
+// export saves all raw Entries of a Data Store to an archive file.
+func export(ctx context.Context, args []string) error {
+	flags := newFlagSet("export", exportUsage)
+	var store storeFlags
+	store.Register(flags)
+	receiptsPath := flags.String("receipts", "",
+		"also save the receipt of the First Entry to this file")
+	allReceipts := flags.Bool("all", false,
+		"save the receipts of every Entry with -receipts")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("missing archive")
+	}
+
+	chainID, err := store.GetChainID()
+	if err != nil {
+		return err
+	}
+	opts := datastore.DownloadOptions{
+		Progress:  printProgress,
+		BatchSize: datastore.DefaultBatchSize,
+		Policy:    datastore.DefaultDownloadPolicy,
+		Pool:      pool(),
+	}
+	a, err := opts.Export(ctx, c, chainID)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := a.WriteTo(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %v Entries of %v to %v\n",
+		len(a.Reveals), chainID, flags.Arg(0))
+
+	if *receiptsPath == "" {
+		return nil
+	}
+	receipts, err := opts.GetReceipts(ctx, c, a.Metadata, *allReceipts)
+	if err != nil {
+		return err
+	}
+	rf, err := os.Create(*receiptsPath)
+	if err != nil {
+		return err
+	}
+	defer rf.Close()
+	if err := datastore.WriteReceipts(rf, receipts); err != nil {
+		return err
+	}
+	if err := rf.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved %v receipts to %v\n",
+		len(receipts), *receiptsPath)
+	return nil
+}
+
+const importUsage = "[flags] <archive>"
+
+// importCmd verifies an archive file offline, and optionally extracts its
+// data.
+func importCmd(ctx context.Context, args []string) error {
+	flags := newFlagSet("import", importUsage)
+	output := flags.String("o", "", `extract the data to this file, "-" for stdout`)
+	receiptsPath := flags.String("receipts", "",
+		"verify the receipts saved by export -receipts in this file")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("missing archive")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	a, err := datastore.Import(f)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "OK: %v Entries of %v, data hash %v\n",
+		len(a.Reveals), a.Entry.ChainID, a.DataHash)
+
+	if *receiptsPath != "" {
+		rf, err := os.Open(*receiptsPath)
+		if err != nil {
+			return err
+		}
+		defer rf.Close()
+		receipts, err := datastore.ReadReceipts(rf)
+		if err != nil {
+			return err
+		}
+		if err := a.VerifyReceipts(receipts); err != nil {
+			return err
+		}
+		r := receipts[0]
+		fmt.Fprintf(os.Stderr,
+			"OK: %v receipts, First Entry in DBlock %v at height %v, %v\n",
+			len(receipts), r.DBlockKeyMR, r.Height(), r.Timestamp())
+		if r.BitcoinTransactionHash != nil {
+			fmt.Fprintf(os.Stderr, "Anchored in Bitcoin transaction %v\n",
+				r.BitcoinTransactionHash)
+		}
+	}
+
+	switch *output {
+	case "":
+		return nil
+	case "-":
+		return a.Extract(os.Stdout)
+	}
+	out, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := a.Extract(out); err != nil {
+		os.Remove(*output)
+		return err
+	}
+	return out.Close()
+}
+
+const replayUsage = "[flags] <archive>"
+
+// replay publishes the Data Store of an archive file, such as one exported
+// from another network, with the same Chain ID and Entry Hashes.
+//
+// Entries on other Chains, such as a pool Chain or the Chains of reused Data
+// Blocks, can only be added to existing Chains, so replay first checks that
+// those Chains exist on this network.
+func replay(ctx context.Context, args []string) error {
+	flags := newFlagSet("replay", replayUsage)
+	yes := flags.Bool("y", false, "publish without asking for confirmation")
+	wait := flags.Bool("wait", false,
+		"wait for the Data Store to be confirmed, and then verify it")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("missing archive")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	a, err := datastore.Import(f)
+	if err != nil {
+		return err
+	}
+
+	es, err := ecEs.GetEsAddress(ctx)
+	if err != nil {
+		return err
+	}
+
+	g := generated{
+		DataHash:    *a.DataHash,
+		Size:        a.Size,
+		Compression: a.Compression,
+	}
+	if g.Generated, err = datastore.Replay(es, a.Reveals); err != nil {
+		return err
+	}
+	g.Print()
+
+	if _, err := datastore.Lookup(ctx, c, &g.ChainID); err == nil {
+		fmt.Println("This Data Store already exists.")
+		return nil
+	}
+
+	if err := checkChains(ctx, g.Generated); err != nil {
+		return err
+	}
+
+	published, err := publish(ctx, g, *yes)
+	if err != nil || !published || !*wait {
+		return err
+	}
+
+	fmt.Println("Waiting for confirmation...")
+	if err := retry.Run(ctx, datastore.DefaultPublishPolicy, nil, nil,
+		func() error { return g.Verify(ctx, c) }); err != nil {
+		return err
+	}
+	fmt.Println("Verified Data Store", g.ChainID)
+	return nil
+}
+
+// checkChains returns an error if any Entry of g, other than those on the new
+// Data Store Chain, is on a Chain that does not exist on the network of c.
+func checkChains(ctx context.Context, g datastore.Generated) error {
+	checked := map[factom.Bytes32]struct{}{g.ChainID: {}}
+	for _, reveal := range g.Reveals {
+		var chainID factom.Bytes32
+		copy(chainID[:], reveal[1:])
+		if _, ok := checked[chainID]; ok {
+			continue
+		}
+		checked[chainID] = struct{}{}
+		eb := factom.EBlock{ChainID: &chainID}
+		inProcess, err := eb.GetChainHead(ctx, c)
+		var jErr jsonrpc2.Error
+		if err != nil && !errors.As(err, &jErr) {
+			return err
+		}
+		if eb.KeyMR == nil && !inProcess {
+			return fmt.Errorf("Chain %v does not exist on this network",
+				chainID)
+		}
+	}
+	return nil
+}
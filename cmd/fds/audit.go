@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+const auditUsage = "[flags] <upload -save file or archive>"
+
+// auditCmd reports which Entries of the Data Store in a file saved by upload
+// -save, or in an archive file, have not been published, and optionally
+// publishes only those Entries.
+//
+// The saved commits are submitted again when repairing, and new commits are
+// only generated for those that expired. The saved file is then updated with
+// any new commits. An archive has no commits, so new commits are generated for
+// all Entries.
+func auditCmd(ctx context.Context, args []string) error {
+	flags := newFlagSet("audit", auditUsage)
+	repair := flags.Bool("repair", false,
+		"publish the missing Entries, generating new commits for any that expired")
+	yes := flags.Bool("y", false, "repair without asking for confirmation")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("missing file")
+	}
+	path := flags.Arg(0)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// The commits are only submitted when repairing, so any address may
+	// be used to audit.
+	var es factom.EsAddress
+	if *repair {
+		es, err = ecEs.GetEsAddress(ctx)
+	} else {
+		es, err = factom.GenerateEsAddress()
+	}
+	if err != nil {
+		return err
+	}
+
+	var g generated
+	saved := !bytes.HasPrefix(data, []byte(datastore.ArchiveMagic))
+	if saved {
+		if err := json.Unmarshal(data, &g); err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+	} else {
+		a, err := datastore.Import(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		g.Generated, err = datastore.Replay(es, a.Reveals)
+		if err != nil {
+			return err
+		}
+	}
+
+	statuses, err := g.Audit(ctx, c)
+	if err != nil {
+		return err
+	}
+	var missing, pending int
+	for _, s := range statuses {
+		switch {
+		case !s.Revealed():
+			fmt.Println("Missing:", s.EntryHash)
+			missing++
+		case !s.Confirmed():
+			pending++
+		}
+	}
+	fmt.Println("Chain ID:", g.ChainID)
+	fmt.Printf("Entries:  %v (%v missing, %v pending confirmation)\n",
+		len(statuses), missing, pending)
+	if missing == 0 || !*repair {
+		return nil
+	}
+
+	if !*yes && !confirm("Repair?") {
+		return nil
+	}
+	opts := datastore.PublishOptions{Progress: printProgress}
+	repaired, err := opts.Repair(ctx, c, es, g.Generated)
+	if saved {
+		// Save any new commits, even if publishing failed, so that
+		// they are reused by the next repair.
+		g.Generated = repaired
+		if err := g.save(path); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("Repaired Data Store", g.ChainID)
+	return nil
+}
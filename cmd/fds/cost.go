@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+const costUsage = "[flags] <file>"
+
+func cost(ctx context.Context, args []string) error {
+	flags := newFlagSet("cost", costUsage)
+	format := flags.String("compression", "gzip",
+		`compression format: "gzip", "zlib", "gzip-seekable", or "none"`)
+	appMetadata := flags.String("metadata", "",
+		"application defined metadata JSON")
+	var namespace Namespace
+	flags.Var(&namespace, "namespace",
+		"Namespace ExtID, may be repeated, prefix with 0x for hex")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("missing file")
+	}
+
+	appMD, err := parseAppMetadata(*appMetadata)
+	if err != nil {
+		return err
+	}
+
+	// The cost does not depend on the key used to sign the commits.
+	es, err := factom.GenerateEsAddress()
+	if err != nil {
+		return err
+	}
+
+	g, err := generateFile(ctx, datastore.GenerateOptions{}, es,
+		flags.Arg(0), *format, appMD, namespace...)
+	if err != nil {
+		return err
+	}
+	g.Print()
+	return nil
+}
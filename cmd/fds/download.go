@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+const downloadUsage = "[flags] (-chainid <chain id> | -hash <data hash>)"
+
+func download(ctx context.Context, args []string) error {
+	flags := newFlagSet("download", downloadUsage)
+	var store storeFlags
+	store.Register(flags)
+	output := flags.String("o", "-", `output file, "-" for stdout`)
+	workers := flags.Int("workers", 0,
+		"number of concurrent Data Block downloads, defaults to the number of CPUs")
+	batch := flags.Int("batch", datastore.DefaultBatchSize,
+		"maximum number of Data Blocks per batch request, 1 to disable batching")
+	flags.Parse(args)
+
+	m, err := store.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := datastore.DownloadOptions{
+		Progress:  printProgress,
+		Workers:   *workers,
+		BatchSize: *batch,
+		Policy:    datastore.DefaultDownloadPolicy,
+		Pool:      pool(),
+	}
+	if *output == "-" {
+		return opts.Download(ctx, c, m, os.Stdout)
+	}
+
+	// Write each Data Block directly to a partial file, so that the
+	// download may be resumed by running the same command again.
+	if err := opts.DownloadFile(ctx, c, m, *output); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Downloaded %v bytes to %v\n", m.Size, *output)
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds/index"
+)
+
+const indexUsage = "[flags]"
+
+// indexCmd scans the blockchain for Data Stores and records them in a local
+// index database.
+func indexCmd(ctx context.Context, args []string) error {
+	flags := newFlagSet("index", indexUsage)
+	dbPath := flags.String("db", "fds-index.db", "index database path")
+	start := flags.Uint("start", 0,
+		"first Directory Block height to scan, if greater than the next height in the index")
+	end := flags.Uint("end", 0,
+		"last Directory Block height to scan, 0 for the latest")
+	follow := flags.Bool("follow", false,
+		"continue to scan new Directory Blocks as they are created")
+	poll := flags.Duration("poll", time.Minute,
+		"interval to poll for new Directory Blocks with -follow")
+	strict := flags.Bool("strict", false,
+		"only record Data Stores with strictly valid Metadata JSON")
+	flags.Parse(args)
+
+	idx, err := index.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	idx.ParseOptions.Strict = *strict
+
+	if *follow {
+		err := idx.Follow(ctx, c, uint32(*start), *poll)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+
+	next, err := idx.NextHeight()
+	if err != nil {
+		return err
+	}
+	if uint32(*start) > next {
+		next = uint32(*start)
+	}
+	last := uint32(*end)
+	if last == 0 {
+		var heights factom.Heights
+		if err := heights.Get(ctx, c); err != nil {
+			return err
+		}
+		last = heights.EntryBlock
+	}
+
+	for height := next; height <= last; height++ {
+		if err := idx.Sync(ctx, c, height, height); err != nil {
+			return fmt.Errorf("height %v: %w", height, err)
+		}
+		fmt.Printf("\rScanned height %v/%v", height, last)
+	}
+	fmt.Println()
+
+	n, err := idx.Count()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Data Stores indexed:", n)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+const infoUsage = "[flags] (-chainid <chain id> | -hash <data hash>)"
+
+func info(ctx context.Context, args []string) error {
+	flags := newFlagSet("info", infoUsage)
+	var store storeFlags
+	store.Register(flags)
+	flags.Parse(args)
+
+	m, err := store.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+	printMetadata(m)
+	return nil
+}
+
+func printMetadata(m datastore.Metadata) {
+	dbiECount, dbECount := m.EntryCounts()
+	fmt.Println("Chain ID:   ", m.Entry.ChainID)
+	fmt.Println("Data Hash:  ", m.DataHash)
+	for i, id := range m.Namespace() {
+		fmt.Printf("Namespace %v: %q\n", i, id)
+	}
+	if m.Pending {
+		fmt.Println("Pending:     not yet confirmed")
+	}
+	if m.EBlockKeyMR != nil {
+		fmt.Println("Height:     ", m.Height)
+		fmt.Println("EBlock:     ", m.EBlockKeyMR)
+		fmt.Println("Created:    ", m.Timestamp)
+	}
+	fmt.Println("Version:    ", m.Version)
+	fmt.Println("Size:       ", m.Size)
+	if m.Compression != nil {
+		fmt.Printf("Compression: %v, %v bytes\n",
+			m.Compression.Format, m.Compression.Size)
+	}
+	if m.IsProof() {
+		fmt.Println("Proof of existence record, data is not stored on chain")
+	} else {
+		fmt.Println("DBI Start:  ", m.DBIStart)
+		fmt.Println("DBI Entries:", dbiECount)
+		fmt.Println("DB Entries: ", dbECount)
+	}
+	if m.AppMetadata != nil {
+		var appMD strings.Builder
+		enc := json.NewEncoder(&appMD)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(m.AppMetadata); err != nil {
+			fmt.Println("Metadata:   ", string(m.AppMetadata))
+			return
+		}
+		fmt.Print("Metadata:    ", appMD.String())
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+// storeFlags identify a Data Store either by its Chain ID, or by its data hash
+// and Namespace.
+type storeFlags struct {
+	ChainID   factom.Bytes32
+	DataHash  factom.Bytes32
+	Namespace Namespace
+	Wait      bool
+	Pending   bool
+}
+
+func (s *storeFlags) Register(flags *flag.FlagSet) {
+	flags.Var(&s.ChainID, "chainid", "Chain ID of the Data Store")
+	flags.Var(&s.DataHash, "hash", "sha256d data hash of the Data Store")
+	flags.Var(&s.Namespace, "namespace",
+		"Namespace ExtID used with -hash, may be repeated, prefix with 0x for hex")
+	flags.BoolVar(&s.Wait, "wait", false,
+		"wait for a pending Data Store to be confirmed")
+	flags.BoolVar(&s.Pending, "pending", false,
+		"allow a Data Store that is not yet confirmed")
+}
+
+// GetChainID returns the Chain ID, computing it from the data hash and
+// namespace if necessary.
+func (s *storeFlags) GetChainID() (*factom.Bytes32, error) {
+	switch {
+	case !s.ChainID.IsZero() && !s.DataHash.IsZero():
+		return nil, fmt.Errorf("-chainid and -hash are mutually exclusive")
+	case !s.ChainID.IsZero():
+		if len(s.Namespace) > 0 {
+			return nil, fmt.Errorf("-namespace requires -hash")
+		}
+		return &s.ChainID, nil
+	case !s.DataHash.IsZero():
+		chainID := factom.ComputeChainID(
+			datastore.NameIDs(&s.DataHash, s.Namespace...))
+		return &chainID, nil
+	}
+	return nil, fmt.Errorf("-chainid or -hash is required")
+}
+
+// Lookup the Metadata for the Data Store.
+func (s *storeFlags) Lookup(ctx context.Context) (datastore.Metadata, error) {
+	chainID, err := s.GetChainID()
+	if err != nil {
+		return datastore.Metadata{}, err
+	}
+	opts := datastore.ParseOptions{AllowPending: s.Pending}
+	if s.Wait {
+		opts.Wait = datastore.DefaultPublishPolicy
+	}
+	var m datastore.Metadata
+	if p := pool(); p != nil {
+		m, err = p.Lookup(ctx, opts, chainID)
+	} else {
+		m, err = opts.Lookup(ctx, c, chainID)
+	}
+	if err != nil {
+		return datastore.Metadata{}, fmt.Errorf("lookup %v: %w",
+			chainID, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,259 @@
+// Command fds stores and retrieves data on the Factom Blockchain using the
+// Factom Data Store protocol.
+//
+// Usage:
+//
+//	fds [global flags] <command> [command flags] [args]
+//
+// The commands are:
+//
+//	upload    Generate, quote and publish a new Data Store for a file.
+//	download  Download the data from a Data Store.
+//	info      Print the Metadata of a Data Store.
+//	cost      Print the Entry Credit cost of storing a file.
+//	verify    Verify a Data Store and optionally a local copy of its data.
+//	alias     Publish an existing Data Store in another Namespace.
+//	prove     Publish a proof of existence record for a file.
+//	export    Save all Entries of a Data Store to an archive file.
+//	import    Verify an archive file offline and optionally extract its data.
+//	replay    Publish the Data Store of an archive file on this network.
+//	audit     Report and repair unpublished Entries of an upload or archive.
+//	index     Scan the blockchain and record Data Stores in a local index.
+//	search    Search the local index for Data Stores.
+//
+// Run "fds <command> -h" for the flags of each command.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+type command struct {
+	Name  string
+	Usage string
+	Run   func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"upload", uploadUsage, upload},
+	{"download", downloadUsage, download},
+	{"info", infoUsage, info},
+	{"cost", costUsage, cost},
+	{"verify", verifyUsage, verify},
+	{"alias", aliasUsage, alias},
+	{"prove", proveUsage, prove},
+	{"export", exportUsage, export},
+	{"import", importUsage, importCmd},
+	{"replay", replayUsage, replay},
+	{"audit", auditUsage, auditCmd},
+	{"index", indexUsage, indexCmd},
+	{"search", searchUsage, search},
+}
+
+var (
+	c       = factom.NewClient()
+	ecEs    ECEsAddress
+	mirrors Mirrors
+)
+
+func main() {
+	flags := flag.NewFlagSet("fds", flag.ExitOnError)
+	flags.StringVar(&c.FactomdServer, "factomd", c.FactomdServer,
+		"factomd API endpoint")
+	flags.Var(&mirrors, "mirror",
+		"additional factomd API endpoint to download from, may be repeated")
+	flags.StringVar(&c.WalletdServer, "walletd", c.WalletdServer,
+		"factom-walletd API endpoint")
+	flags.Var(&ecEs, "ecadr",
+		"Es or EC address to pay for entries, EC addresses are queried from factom-walletd")
+	timeout := flags.Duration("timeout", 10*time.Second,
+		"timeout for each API request")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(),
+			"Usage: fds [global flags] <command> [command flags] [args]\n\nCommands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(flags.Output(), "  %v %v\n", cmd.Name, cmd.Usage)
+		}
+		fmt.Fprintf(flags.Output(), "\nGlobal flags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+	c.Factomd.Timeout = *timeout
+	c.Walletd.Timeout = *timeout
+
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	for _, cmd := range commands {
+		if cmd.Name != args[0] {
+			continue
+		}
+		if err := cmd.Run(ctx, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "fds %v: %v\n", cmd.Name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "fds: unknown command %q\n", args[0])
+	flags.Usage()
+	os.Exit(2)
+}
+
+// newFlagSet returns a FlagSet for the named command with a usage message.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet("fds "+name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fds %v %v\n", name, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// printProgress prints p to stderr, overwriting the previous line until the
+// stage is done.
+func printProgress(p datastore.Progress) {
+	fmt.Fprintf(os.Stderr, "\r%-8v %v/%v", p.Stage, p.Done, p.Total)
+	if p.TotalBytes > 0 {
+		fmt.Fprintf(os.Stderr, " (%v/%v bytes)", p.Bytes, p.TotalBytes)
+	}
+	if p.Done == p.Total {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// Mirrors are additional factomd API endpoints.
+type Mirrors []string
+
+// Set appends the endpoint.
+func (m *Mirrors) Set(endpoint string) error {
+	*m = append(*m, endpoint)
+	return nil
+}
+
+func (m Mirrors) String() string { return strings.Join(m, ",") }
+
+// pool returns a datastore.Pool of c and all mirrors, or nil if there are no
+// mirrors.
+func pool() *datastore.Pool {
+	if len(mirrors) == 0 {
+		return nil
+	}
+	clients := []*factom.Client{c}
+	for _, endpoint := range mirrors {
+		mirror := factom.NewClient()
+		mirror.FactomdServer = endpoint
+		mirror.Factomd.Timeout = c.Factomd.Timeout
+		clients = append(clients, mirror)
+	}
+	return datastore.NewPool(clients...)
+}
+
+// ECEsAddress is an EC address along with its Es address, which is queried
+// from factom-walletd if only the EC address is set.
+type ECEsAddress struct {
+	EC factom.ECAddress
+	Es factom.EsAddress
+}
+
+// Set parses adrStr as either an Es or EC address.
+func (e *ECEsAddress) Set(adrStr string) error {
+	if err := e.EC.Set(adrStr); err != nil {
+		if err := e.Es.Set(adrStr); err != nil {
+			return err
+		}
+		e.EC = e.Es.ECAddress()
+	}
+	return nil
+}
+
+func (e ECEsAddress) String() string {
+	if factom.Bytes32(e.EC).IsZero() {
+		return ""
+	}
+	return e.EC.String()
+}
+
+// GetEsAddress returns the Es address, querying factom-walletd if necessary.
+func (e *ECEsAddress) GetEsAddress(ctx context.Context) (factom.EsAddress, error) {
+	if factom.Bytes32(e.EC).IsZero() {
+		return factom.EsAddress{}, fmt.Errorf("-ecadr is required")
+	}
+	if factom.Bytes32(e.Es).IsZero() {
+		es, err := e.EC.GetEsAddress(ctx, c)
+		if err != nil {
+			return factom.EsAddress{}, fmt.Errorf(
+				"factom-walletd: %w", err)
+		}
+		e.Es = es
+	}
+	return e.Es, nil
+}
+
+// Namespace is a repeatable flag.Value for the ExtIDs of a Data Store
+// Namespace. Values prefixed with "0x" are parsed as hex.
+type Namespace []factom.Bytes
+
+// Set appends the parsed namespace ID.
+func (ns *Namespace) Set(id string) error {
+	if strings.HasPrefix(id, "0x") {
+		var b factom.Bytes
+		if err := b.Set(id[2:]); err != nil {
+			return err
+		}
+		*ns = append(*ns, b)
+		return nil
+	}
+	*ns = append(*ns, factom.Bytes(id))
+	return nil
+}
+
+func (ns Namespace) String() string {
+	ids := make([]string, len(ns))
+	for i, id := range ns {
+		ids[i] = fmt.Sprintf("%q", id)
+	}
+	return strings.Join(ids, ",")
+}
+
+// ChainIDs is a repeatable flag.Value for Chain IDs.
+type ChainIDs []factom.Bytes32
+
+// Set appends the parsed Chain ID.
+func (ids *ChainIDs) Set(id string) error {
+	var chainID factom.Bytes32
+	if err := chainID.Set(id); err != nil {
+		return err
+	}
+	*ids = append(*ids, chainID)
+	return nil
+}
+
+func (ids ChainIDs) String() string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strings.Join(strs, ",")
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds"
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+// newTestServer starts a factomdtest.Server for the commands to use, with a
+// funded -ecadr.
+func newTestServer(t *testing.T) *factomdtest.Server {
+	s := factomdtest.NewServer()
+	s.Handle("entry-credit-balance",
+		func(context.Context, json.RawMessage) interface{} {
+			return struct {
+				Balance uint64 `json:"balance"`
+			}{1e6}
+		})
+	c = s.Client()
+	mirrors = nil
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(t, err)
+	ecEs = ECEsAddress{EC: es.ECAddress(), Es: es}
+	return s
+}
+
+func TestCommands(t *testing.T) {
+	require := require.New(t)
+
+	s := newTestServer(t)
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "fds-cmd")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	data := make([]byte, 3*factom.EntryMaxDataLen+7)
+	rand.Read(data)
+	require.NoError(ioutil.WriteFile(path("data"), data, 0644))
+	dataHash := datastore.ComputeDataHash(data)
+	chainID := factom.ComputeChainID(datastore.NameIDs(&dataHash)).String()
+
+	run := func(cmd func(context.Context, []string) error,
+		args ...string) error {
+		return cmd(context.Background(), args)
+	}
+
+	require.NoError(run(cost, path("data")))
+	assert.Error(t, run(upload, "-y"), "missing file")
+
+	require.NoError(run(upload, "-y", "-compression", "zlib", path("data")))
+	s.Confirm()
+
+	require.NoError(run(info, "-chainid", chainID))
+	require.NoError(run(download, "-chainid", chainID, "-o", path("out")))
+	out, err := ioutil.ReadFile(path("out"))
+	require.NoError(err)
+	assert.Equal(t, data, out)
+	require.NoError(run(verify, "-chainid", chainID))
+	require.NoError(run(verify, "-hash", dataHash.String(), path("data")))
+	require.NoError(ioutil.WriteFile(path("other"), data[1:], 0644))
+	assert.Error(t, run(verify, "-chainid", chainID, path("other")))
+
+	// An archive may be verified and extracted offline.
+	require.NoError(run(export, "-chainid", chainID, "-receipts",
+		path("receipts"), path("archive")))
+	require.NoError(run(importCmd, "-receipts", path("receipts"),
+		"-o", path("extracted"), path("archive")))
+	out, err = ioutil.ReadFile(path("extracted"))
+	require.NoError(err)
+	assert.Equal(t, data, out)
+	require.NoError(run(auditCmd, path("archive")))
+
+	// A proof of existence record stores no data.
+	require.NoError(run(prove, "-y", "-namespace", "notary", path("other")))
+	s.Confirm()
+	otherHash := datastore.ComputeDataHash(data[1:])
+	require.NoError(run(verify, "-hash", otherHash.String(),
+		"-namespace", "notary", path("other")))
+	assert.Error(t, run(verify, "-hash", otherHash.String(),
+		"-namespace", "notary"), "proof without a file")
+	assert.Error(t, run(verify, "-hash", otherHash.String(),
+		"-namespace", "notary", path("data")))
+}
+
+func TestAudit(t *testing.T) {
+	require := require.New(t)
+
+	s := newTestServer(t)
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "fds-cmd")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	// Interrupt an upload by failing the reveal of its last Data Block.
+	reveal := s.Method("reveal-entry")
+	interrupt := func(name string) (saved generated) {
+		data := make([]byte, 2*factom.EntryMaxDataLen+7)
+		rand.Read(data)
+		require.NoError(ioutil.WriteFile(path(name), data, 0644))
+
+		last := data[len(data)-7:]
+		s.Handle("reveal-entry", func(ctx context.Context,
+			params json.RawMessage) interface{} {
+			var p struct {
+				Entry factom.Bytes `json:"entry"`
+			}
+			var e factom.Entry
+			if json.Unmarshal(params, &p) == nil &&
+				e.UnmarshalBinary(p.Entry) == nil &&
+				bytes.Equal(e.Content, last) {
+				return factomdtest.ErrorInvalidCommit
+			}
+			return reveal(ctx, params)
+		})
+		defer s.Handle("reveal-entry", reveal)
+
+		assert.Error(t, upload(context.Background(), []string{"-y",
+			"-compression", "none", "-save", path(name + ".json"),
+			path(name)}))
+		saved, err := loadSaved(path(name + ".json"))
+		require.NoError(err)
+		return saved
+	}
+	audit := func(name string) generated {
+		require.NoError(auditCmd(context.Background(), []string{
+			"-repair", "-y", path(name + ".json")}))
+		s.Confirm()
+		require.NoError(auditCmd(context.Background(), []string{
+			path(name + ".json")}))
+		repaired, err := loadSaved(path(name + ".json"))
+		require.NoError(err)
+		require.NoError(repaired.Verify(context.Background(), c))
+		return repaired
+	}
+
+	// The saved commits are submitted again.
+	saved := interrupt("a")
+	assert.Equal(t, saved, audit("a"))
+
+	// Only expired commits are replaced, and saved for the next audit.
+	saved = interrupt("b")
+	s.ExpireCommits()
+	repaired := audit("b")
+	n := len(saved.TxIDs)
+	assert.Equal(t, saved.TxIDs[:n-1], repaired.TxIDs[:n-1])
+	assert.NotEqual(t, saved.TxIDs[n-1], repaired.TxIDs[n-1])
+}
+
+// loadSaved reads a file saved by upload -save.
+func loadSaved(path string) (generated, error) {
+	var g generated
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return g, err
+	}
+	return g, json.Unmarshal(data, &g)
+}
+
+func TestReplay(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "fds-cmd")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "archive")
+
+	// A Data Store on a pool Chain.
+	poolE := factom.Entry{ExtIDs: []factom.Bytes{factom.Bytes("pool")}}
+	poolChainID := factom.ComputeChainID(poolE.ExtIDs)
+	poolE.ChainID = &poolChainID
+	pool, err := poolE.MarshalBinary()
+	require.NoError(err)
+
+	data := make([]byte, 2*factom.EntryMaxDataLen)
+	rand.Read(data)
+	dataHash := datastore.ComputeDataHash(data)
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	g, err := datastore.GenerateOptions{PoolChainID: &poolChainID}.Generate(
+		nil, nil, es, bytes.NewReader(data), nil, uint64(len(data)),
+		&dataHash, nil)
+	require.NoError(err)
+	f, err := os.Create(archive)
+	require.NoError(err)
+	_, err = datastore.Archive{Reveals: g.Reveals}.WriteTo(f)
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	s := newTestServer(t)
+	defer s.Close()
+	assert.EqualError(t, replay(context.Background(),
+		[]string{"-y", archive}), fmt.Sprintf(
+		"Chain %v does not exist on this network", poolChainID))
+
+	// Nothing is published, or waited for, if the user declines.
+	s.AddEBlock(pool)
+	stdin := os.Stdin
+	os.Stdin, err = os.Open(os.DevNull)
+	require.NoError(err)
+	defer func() { os.Stdin.Close(); os.Stdin = stdin }()
+	require.NoError(replay(context.Background(), []string{"-wait", archive}))
+	assert.Zero(t, s.Calls("commit-entry")+s.Calls("commit-chain"))
+
+	require.NoError(replay(context.Background(), []string{"-y", archive}))
+	s.Confirm()
+	_, err = datastore.Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+const proveUsage = "[flags] <file>"
+
+// prove publishes a proof of existence record for a file, without uploading
+// its data.
+func prove(ctx context.Context, args []string) error {
+	flags := newFlagSet("prove", proveUsage)
+	appMetadata := flags.String("metadata", "",
+		"application defined metadata JSON")
+	var namespace Namespace
+	flags.Var(&namespace, "namespace",
+		"Namespace ExtID, may be repeated, prefix with 0x for hex")
+	yes := flags.Bool("y", false, "publish without asking for confirmation")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("missing file")
+	}
+
+	appMD, err := parseAppMetadata(*appMetadata)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		return fmt.Errorf("%v: empty file", flags.Arg(0))
+	}
+
+	es, err := ecEs.GetEsAddress(ctx)
+	if err != nil {
+		return err
+	}
+
+	g := generated{
+		DataHash: factom.Bytes32(sha256.Sum256(hash.Sum(nil))),
+		Size:     uint64(size),
+	}
+	if g.Generated, err = datastore.Proof(es, &g.DataHash, g.Size, appMD,
+		namespace...); err != nil {
+		return err
+	}
+	g.Print()
+
+	if _, err := datastore.Lookup(ctx, c, &g.ChainID); err == nil {
+		fmt.Println("This record already exists.")
+		return nil
+	}
+
+	_, err = publish(ctx, g, *yes)
+	return err
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/fds/index"
+)
+
+const searchUsage = "[flags]"
+
+// metadataFilters is a repeatable flag.Value of path=value pairs. Values that
+// are not valid JSON are treated as strings.
+type metadataFilters map[string]interface{}
+
+func (f metadataFilters) Set(filter string) error {
+	eq := strings.Index(filter, "=")
+	if eq <= 0 {
+		return fmt.Errorf("expected <path>=<value>")
+	}
+	path, value := filter[:eq], filter[eq+1:]
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		v = value
+	}
+	f[path] = v
+	return nil
+}
+
+func (f metadataFilters) String() string {
+	filters := make([]string, 0, len(f))
+	for path, v := range f {
+		filters = append(filters, fmt.Sprintf("%v=%v", path, v))
+	}
+	return strings.Join(filters, ",")
+}
+
+// search queries a local index database created with the index command.
+func search(ctx context.Context, args []string) error {
+	flags := newFlagSet("search", searchUsage)
+	dbPath := flags.String("db", "fds-index.db", "index database path")
+	var q index.Query
+	var namespace Namespace
+	flags.Var(&namespace, "namespace",
+		"Namespace prefix ExtID, may be repeated, prefix with 0x for hex")
+	flags.Uint64Var(&q.MinSize, "min-size", 0, "minimum data size")
+	flags.Uint64Var(&q.MaxSize, "max-size", 0, "maximum data size")
+	minHeight := flags.Uint("min-height", 0, "minimum creation height")
+	maxHeight := flags.Uint("max-height", 0, "maximum creation height")
+	flags.StringVar(&q.Compression, "compression", "",
+		`compression format: "gzip", "zlib", or "none"`)
+	proof := flags.String("proof", "",
+		`match proof of existence records: "true" or "false"`)
+	metadata := make(metadataFilters)
+	flags.Var(metadata, "metadata",
+		"AppMetadata <path>=<JSON value> filter, may be repeated")
+	flags.IntVar(&q.Limit, "limit", 0, "maximum number of results")
+	asJSON := flags.Bool("json", false, "print results as JSON lines")
+	flags.Parse(args)
+
+	q.Namespace = namespace
+	q.MinHeight, q.MaxHeight = uint32(*minHeight), uint32(*maxHeight)
+	q.Metadata = metadata
+	if *proof != "" {
+		p, err := strconv.ParseBool(*proof)
+		if err != nil {
+			return fmt.Errorf("-proof: %w", err)
+		}
+		q.Proof = &p
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		return err
+	}
+	idx, err := index.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+
+	records, err := idx.Query(q)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, r := range records {
+		if *asJSON {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Printf("%v height:%v size:%v namespace:%v proof:%v\n",
+			r.ChainID, r.Height, r.Size, Namespace(r.Namespace),
+			r.Proof)
+	}
+	return nil
+}
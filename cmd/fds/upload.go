@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+const uploadUsage = "[flags] <file>"
+
+// generated holds the output of datastore.Generate for a file.
+type generated struct {
+	DataHash    factom.Bytes32
+	Size        uint64
+	Compression *datastore.Compression
+	datastore.Generated
+}
+
+// generateFile reads the file at path and generates the entries for a new Data
+// Store.
+func generateFile(ctx context.Context, opts datastore.GenerateOptions,
+	es factom.EsAddress, path, format string,
+	appMetadata json.RawMessage, namespace ...factom.Bytes) (generated, error) {
+
+	var g generated
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return g, err
+	}
+	if len(data) == 0 {
+		return g, fmt.Errorf("%v: empty file", path)
+	}
+	g.Size = uint64(len(data))
+	g.DataHash = datastore.ComputeDataHash(data)
+
+	cData, compression, err := datastore.Compress(format, data)
+	if err != nil {
+		return g, err
+	}
+	g.Compression = compression
+
+	g.Generated, err = opts.Generate(ctx, c, es, bytes.NewReader(cData),
+		compression, g.Size, &g.DataHash, appMetadata, namespace...)
+	return g, err
+}
+
+func (g generated) Print() {
+	fmt.Println("Chain ID:   ", g.ChainID)
+	fmt.Println("Data Hash:  ", g.DataHash)
+	fmt.Println("Size:       ", g.Size)
+	if g.Compression != nil {
+		fmt.Printf("Compression: %v, %v bytes\n",
+			g.Compression.Format, g.Compression.Size)
+	}
+	fmt.Println("Entries:    ", len(g.Reveals))
+	fmt.Println("Cost:       ", g.TotalCost, "EC")
+	if g.SavedCost > 0 {
+		fmt.Println("Saved:      ", g.SavedCost, "EC by reusing Data Blocks")
+	}
+}
+
+// save writes g as JSON to the file at path, so that its Entries may later be
+// audited and repaired using the same commits. See auditCmd.
+func (g generated) save(path string) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	// The commits are signed, so keep them private.
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// parseAppMetadata validates the -metadata flag.
+func parseAppMetadata(appMetadata string) (json.RawMessage, error) {
+	if len(appMetadata) == 0 {
+		return nil, nil
+	}
+	if !json.Valid([]byte(appMetadata)) {
+		return nil, fmt.Errorf("-metadata: invalid JSON")
+	}
+	return json.RawMessage(appMetadata), nil
+}
+
+func upload(ctx context.Context, args []string) error {
+	flags := newFlagSet("upload", uploadUsage)
+	format := flags.String("compression", "gzip",
+		`compression format: "gzip", "zlib", "gzip-seekable", or "none"`)
+	appMetadata := flags.String("metadata", "",
+		"application defined metadata JSON")
+	var namespace Namespace
+	flags.Var(&namespace, "namespace",
+		"Namespace ExtID, may be repeated, prefix with 0x for hex")
+	var reuse ChainIDs
+	flags.Var(&reuse, "reuse",
+		"Chain ID of a Data Store whose Data Blocks may be reused, may be repeated")
+	var poolChainID factom.Bytes32
+	flags.Var(&poolChainID, "pool",
+		"Chain ID of an existing chain for the DBI and Data Block entries")
+	save := flags.String("save", "",
+		"save the generated Entries and commits to this file, for audit")
+	yes := flags.Bool("y", false, "publish without asking for confirmation")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("missing file")
+	}
+
+	appMD, err := parseAppMetadata(*appMetadata)
+	if err != nil {
+		return err
+	}
+
+	es, err := ecEs.GetEsAddress(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts := datastore.GenerateOptions{Progress: printProgress}
+	if !poolChainID.IsZero() {
+		opts.PoolChainID = &poolChainID
+	}
+	if len(reuse) > 0 {
+		opts.Known = make(datastore.KnownBlocks)
+		for _, chainID := range reuse {
+			m, err := datastore.Lookup(ctx, c, &chainID)
+			if err != nil {
+				return fmt.Errorf("lookup %v: %w", chainID, err)
+			}
+			if err := opts.Known.AddStore(ctx, c, m); err != nil {
+				return err
+			}
+		}
+	}
+
+	g, err := generateFile(ctx, opts, es, flags.Arg(0), *format, appMD,
+		namespace...)
+	if err != nil {
+		return err
+	}
+	g.Print()
+	if *save != "" {
+		if err := g.save(*save); err != nil {
+			return err
+		}
+	}
+
+	if _, err := datastore.Lookup(ctx, c, &g.ChainID); err == nil {
+		fmt.Println("This Data Store already exists.")
+		return nil
+	}
+
+	_, err = publish(ctx, g, *yes)
+	return err
+}
+
+// publish checks the EC balance, asks for confirmation unless yes is true,
+// and then submits all commits and reveals of g, printing progress. It returns
+// false if the user declined to publish.
+func publish(ctx context.Context, g generated, yes bool) (bool, error) {
+	balance, err := ecEs.EC.GetBalance(ctx, c)
+	if err != nil {
+		return false, err
+	}
+	fmt.Println("EC Balance: ", balance, "EC")
+	if balance < uint64(g.TotalCost) {
+		return false, fmt.Errorf("insufficient balance")
+	}
+
+	if !yes && !confirm("Publish?") {
+		return false, nil
+	}
+
+	if err := (datastore.PublishOptions{Progress: printProgress}).Publish(
+		ctx, c, g.TxIDs, g.EntryHashes, g.Commits, g.Reveals); err != nil {
+		return false, err
+	}
+
+	fmt.Println("Published Data Store", g.ChainID)
+	return true, nil
+}
+
+// confirm prompts the user on stdin and returns true if they answer yes.
+func confirm(prompt string) bool {
+	fmt.Printf("%v [y/N] ", prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
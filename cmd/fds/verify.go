@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+const verifyUsage = "[flags] (-chainid <chain id> | -hash <data hash>) [file]"
+
+// verify downloads and verifies the on-chain data, or if a file is given,
+// verifies that the file matches the Data Store or proof of existence record.
+func verify(ctx context.Context, args []string) error {
+	flags := newFlagSet("verify", verifyUsage)
+	var store storeFlags
+	store.Register(flags)
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return fmt.Errorf("too many arguments")
+	}
+
+	m, err := store.Lookup(ctx)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		if m.IsProof() {
+			return fmt.Errorf(
+				"proof of existence record: a file is required")
+		}
+		if err := m.Download(ctx, c, ioutil.Discard); err != nil {
+			return err
+		}
+		fmt.Println("OK: on-chain data matches", m.DataHash)
+		return nil
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := m.Verify(f); err != nil {
+		return err
+	}
+	fmt.Println("OK:", flags.Arg(0), "matches", m.DataHash)
+	return nil
+}
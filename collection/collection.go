@@ -0,0 +1,305 @@
+// Package collection publishes and restores directory trees as a single unit
+// on the Factom Blockchain using Data Stores.
+//
+// A collection is a Data Store, called the manifest, whose data is a JSON
+// Manifest listing the path, size, mode, and Data Store of each member file.
+// Each member file is stored in its own Data Store, within the same
+// Namespace as the manifest, so identical files are only ever stored once.
+package collection
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+// Version of the Manifest format.
+const Version = "1.0"
+
+// Manifest lists the member files of a collection.
+type Manifest struct {
+	// The Version of the Manifest format.
+	Version string `json:"collection"`
+
+	// The member files, sorted by Path.
+	Files []File `json:"files"`
+}
+
+// File describes a member file of a collection.
+type File struct {
+	// Slash separated path relative to the root of the collection.
+	Path string `json:"path"`
+
+	// The size of the file.
+	Size uint64 `json:"size"`
+
+	// The permission bits of the file.
+	Mode os.FileMode `json:"mode"`
+
+	// The Data Store holding the file data. Since Data Stores may not be
+	// empty, these are omitted for empty files.
+	ChainID  *factom.Bytes32 `json:"chainid,omitempty"`
+	DataHash *factom.Bytes32 `json:"data-hash,omitempty"`
+}
+
+// Generate the Data Stores for all regular files within dir, and the manifest
+// Data Store which lists them, all within the given namespace. Data is
+// compressed using format, which may be "gzip", "zlib", or "none".
+//
+// The appMetadata is attached to the manifest. The "filename" of each file is
+// attached to its Data Store as the AppMetadata.
+//
+// The manifest Data Store is returned, followed by the Data Stores of the
+// files. Files with identical content share a single Data Store, and Data
+// Blocks shared between files are only created once, so all files must be
+// published. The manifest should be published last so that it is never
+// available before its files. See Publish.
+func Generate(ctx context.Context, es factom.EsAddress, dir, format string,
+	appMetadata json.RawMessage, namespace ...factom.Bytes) (
+	manifest datastore.Generated, files []datastore.Generated, err error) {
+
+	m := Manifest{Version: Version}
+	opts := datastore.GenerateOptions{Known: make(datastore.KnownBlocks)}
+	generated := make(map[factom.Bytes32]struct{})
+	err = filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%v: unsupported file type", name)
+		}
+
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		f := File{
+			Path: filepath.ToSlash(rel),
+			Size: uint64(info.Size()),
+			Mode: info.Mode().Perm(),
+		}
+		if f.Size > 0 {
+			data, err := ioutil.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			fileMD, err := json.Marshal(struct {
+				Filename string `json:"filename"`
+			}{info.Name()})
+			if err != nil {
+				return err
+			}
+			s, dataHash, err := generate(ctx, opts, es, data,
+				format, fileMD, namespace...)
+			if err != nil {
+				return fmt.Errorf("%v: %w", name, err)
+			}
+			f.ChainID, f.DataHash = &s.ChainID, &dataHash
+			if _, ok := generated[s.ChainID]; !ok {
+				generated[s.ChainID] = struct{}{}
+				files = append(files, s)
+			}
+		}
+		m.Files = append(m.Files, f)
+		return nil
+	})
+	if err != nil {
+		return datastore.Generated{}, nil, err
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return datastore.Generated{}, nil, err
+	}
+	manifest, _, err = generate(ctx, opts, es, data, format,
+		appMetadata, namespace...)
+	if err != nil {
+		return datastore.Generated{}, nil, fmt.Errorf("manifest: %w", err)
+	}
+	return manifest, files, nil
+}
+
+func generate(ctx context.Context, opts datastore.GenerateOptions,
+	es factom.EsAddress, data []byte, format string,
+	appMetadata json.RawMessage, namespace ...factom.Bytes) (
+	datastore.Generated, factom.Bytes32, error) {
+	dataHash := datastore.ComputeDataHash(data)
+	cData, compression, err := datastore.Compress(format, data)
+	if err != nil {
+		return datastore.Generated{}, dataHash, err
+	}
+	g, err := opts.Generate(ctx, nil, es, bytes.NewReader(cData),
+		compression, uint64(len(data)), &dataHash, appMetadata,
+		namespace...)
+	return g, dataHash, err
+}
+
+// Publish the Data Stores of the files that do not already exist, and then the
+// manifest, as returned by Generate.
+//
+// Files that exist may have been published by an earlier, interrupted call, or
+// may be shared with another collection. The manifest is published last so
+// that it is never available before its files.
+func Publish(ctx context.Context, c *factom.Client,
+	manifest datastore.Generated, files []datastore.Generated) error {
+	for _, s := range files {
+		if _, err := datastore.Lookup(ctx, c, &s.ChainID); err == nil {
+			continue
+		}
+		if err := s.Publish(ctx, c); err != nil {
+			return fmt.Errorf("%v: %w", s.ChainID, err)
+		}
+	}
+	if err := manifest.Publish(ctx, c); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	return nil
+}
+
+// Lookup downloads and parses the Manifest of the collection with the given
+// manifest chainID.
+func Lookup(ctx context.Context, c *factom.Client,
+	chainID *factom.Bytes32) (Manifest, error) {
+	md, err := datastore.Lookup(ctx, c, chainID)
+	if err != nil {
+		return Manifest{}, err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, md.Size))
+	if err := md.Download(ctx, c, buf); err != nil {
+		return Manifest{}, err
+	}
+	return Parse(buf.Bytes())
+}
+
+// Parse and validate a Manifest.
+func Parse(data []byte) (Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Manifest{}, err
+	}
+	if m.Version != Version {
+		return Manifest{}, fmt.Errorf(`unsupported "collection" version`)
+	}
+	paths := make(map[string]struct{}, len(m.Files))
+	for _, f := range m.Files {
+		if !validPath(f.Path) {
+			return Manifest{}, fmt.Errorf("invalid path: %q", f.Path)
+		}
+		if _, ok := paths[f.Path]; ok {
+			return Manifest{}, fmt.Errorf("duplicate path: %q", f.Path)
+		}
+		paths[f.Path] = struct{}{}
+		if f.Mode&^os.ModePerm != 0 {
+			return Manifest{}, fmt.Errorf("%v: invalid mode", f.Path)
+		}
+		if (f.Size > 0) != (f.ChainID != nil && f.DataHash != nil) {
+			return Manifest{}, fmt.Errorf(
+				"%v: invalid data store", f.Path)
+		}
+	}
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+	return m, nil
+}
+
+// validPath returns true if p is a clean, relative, slash separated path that
+// does not escape the root of the collection.
+func validPath(p string) bool {
+	return p != "" && p != "." && path.Clean(p) == p &&
+		!path.IsAbs(p) && p != ".." && !strings.HasPrefix(p, "../") &&
+		!strings.Contains(p, "\\")
+}
+
+// Select returns the Files in m whose Path equals, or is within the directory
+// of, any of the given paths. If no paths are given, all Files are returned.
+func (m Manifest) Select(paths ...string) []File {
+	if len(paths) == 0 {
+		return m.Files
+	}
+	var files []File
+	for _, f := range m.Files {
+		for _, p := range paths {
+			p = strings.Trim(path.Clean("/"+p), "/")
+			if p == "" || f.Path == p || strings.HasPrefix(f.Path, p+"/") {
+				files = append(files, f)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// Extract downloads the Files in m selected by paths, see Manifest.Select,
+// into dir, creating any required directories.
+//
+// The Data Store of each File is verified to match the DataHash and Size in
+// the Manifest. Each file is written to a temporary file and only renamed
+// once its data has been verified.
+func (m Manifest) Extract(ctx context.Context, c *factom.Client,
+	dir string, paths ...string) error {
+	files := m.Select(paths...)
+	if len(paths) > 0 && len(files) == 0 {
+		return fmt.Errorf("no files match %q", paths)
+	}
+	for _, f := range files {
+		if err := f.Extract(ctx, c, dir); err != nil {
+			return fmt.Errorf("%v: %w", f.Path, err)
+		}
+	}
+	return nil
+}
+
+// Extract downloads f into its Path within dir.
+func (f File) Extract(ctx context.Context, c *factom.Client, dir string) error {
+	if !validPath(f.Path) {
+		return fmt.Errorf("invalid path")
+	}
+	name := filepath.Join(dir, filepath.FromSlash(f.Path))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	if f.Size == 0 {
+		return ioutil.WriteFile(name, nil, f.Mode.Perm())
+	}
+
+	md, err := datastore.Lookup(ctx, c, f.ChainID)
+	if err != nil {
+		return err
+	}
+	if *md.DataHash != *f.DataHash || md.Size != f.Size {
+		return fmt.Errorf("data store does not match manifest")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".fds-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := md.Download(ctx, c, tmp); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(f.Mode.Perm()); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,173 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestCollection(t *testing.T) {
+	require := require.New(t)
+
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	src, err := ioutil.TempDir("", "fds-collection")
+	require.NoError(err)
+	defer os.RemoveAll(src)
+
+	data := make([]byte, 3*factom.EntryMaxDataLen)
+	rand.Read(data)
+	files := map[string][]byte{
+		"README":         []byte("hello"),
+		"empty":          nil,
+		"data/a.bin":     data,
+		"data/copy.bin":  data,
+		"data/sub/b.txt": []byte("b"),
+	}
+	for name, data := range files {
+		name = filepath.Join(src, filepath.FromSlash(name))
+		require.NoError(os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(ioutil.WriteFile(name, data, 0640))
+	}
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	manifest, stores, err := Generate(nil, es, src, "gzip", nil,
+		factom.Bytes("test"))
+	require.NoError(err)
+	// Identical and empty files do not get their own Data Store.
+	require.Len(stores, 3)
+	for _, s := range append(stores, manifest) {
+		factomd.AddEBlock(s.Reveals...)
+	}
+
+	m, err := Lookup(nil, c, &manifest.ChainID)
+	require.NoError(err)
+	require.Len(m.Files, len(files))
+	assert.Equal(t, "README", m.Files[0].Path)
+	assert.Equal(t, *m.Files[1].ChainID, *m.Files[2].ChainID)
+	assert.Nil(t, m.Files[4].ChainID)
+
+	dst, err := ioutil.TempDir("", "fds-collection")
+	require.NoError(err)
+	defer os.RemoveAll(dst)
+
+	require.NoError(m.Extract(nil, c, dst, "data/sub"))
+	_, err = os.Stat(filepath.Join(dst, "README"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Error(t, m.Extract(nil, c, dst, "data/sub/missing"))
+
+	require.NoError(m.Extract(nil, c, dst))
+	for name, data := range files {
+		name = filepath.Join(dst, filepath.FromSlash(name))
+		info, err := os.Stat(name)
+		require.NoError(err)
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm(), name)
+		extracted, err := ioutil.ReadFile(name)
+		require.NoError(err)
+		assert.Equal(t, len(data), len(extracted), name)
+		assert.Equal(t, string(data), string(extracted), name)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, manifest := range []string{
+		`{}`,
+		`{"collection":"2.0","files":[]}`,
+		`{"collection":"1.0","files":[{"path":"../etc/passwd"}]}`,
+		`{"collection":"1.0","files":[{"path":"/etc/passwd"}]}`,
+		`{"collection":"1.0","files":[{"path":"a/./b"}]}`,
+		`{"collection":"1.0","files":[{"path":"a"},{"path":"a"}]}`,
+		`{"collection":"1.0","files":[{"path":"a","size":1}]}`,
+		`{"collection":"1.0","files":[{"path":"a","mode":2147484159}]}`,
+	} {
+		_, err := Parse([]byte(manifest))
+		assert.Error(t, err, manifest)
+	}
+
+	m, err := Parse([]byte(`{"collection":"1.0","files":[` +
+		`{"path":"b/c","mode":420},{"path":"a","mode":420}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "a", m.Files[0].Path)
+	assert.Len(t, m.Select("b"), 1)
+	assert.Len(t, m.Select("b/"), 1)
+	assert.Len(t, m.Select("/"), 2)
+	assert.Len(t, m.Select("c"), 0)
+}
+
+func TestPublish(t *testing.T) {
+	require := require.New(t)
+
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	src, err := ioutil.TempDir("", "fds-collection")
+	require.NoError(err)
+	defer os.RemoveAll(src)
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(ioutil.WriteFile(filepath.Join(src, name),
+			[]byte(name), 0640))
+	}
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	manifest, stores, err := Generate(nil, es, src, "none", nil)
+	require.NoError(err)
+	require.Len(stores, 3)
+
+	// Publish one file ahead of time, as if by an interrupted Publish.
+	require.NoError(stores[0].Publish(nil, c))
+	factomd.Confirm()
+
+	// Record the Chain ID of each revealed Entry.
+	var revealed []factom.Bytes32
+	reveal := factomd.Method("reveal-entry")
+	factomd.Handle("reveal-entry", func(ctx context.Context,
+		params json.RawMessage) interface{} {
+		var p struct {
+			Entry factom.Bytes `json:"entry"`
+		}
+		if err := json.Unmarshal(params, &p); err == nil &&
+			len(p.Entry) >= factom.EntryHeaderLen {
+			var chainID factom.Bytes32
+			copy(chainID[:], p.Entry[1:])
+			revealed = append(revealed, chainID)
+		}
+		return reveal(ctx, params)
+	})
+
+	calls := factomd.Calls("commit-chain")
+	require.NoError(Publish(nil, c, manifest, stores))
+	factomd.Confirm()
+
+	// The existing file is skipped, and the manifest is published last.
+	assert.Equal(t, 3, factomd.Calls("commit-chain")-calls)
+	assert.NotContains(t, revealed, stores[0].ChainID)
+	require.NotEmpty(revealed)
+	assert.Equal(t, manifest.ChainID, revealed[len(revealed)-1])
+
+	m, err := Lookup(nil, c, &manifest.ChainID)
+	require.NoError(err)
+	dst, err := ioutil.TempDir("", "fds-collection")
+	require.NoError(err)
+	defer os.RemoveAll(dst)
+	require.NoError(m.Extract(nil, c, dst))
+	for _, name := range []string{"a", "b", "c"} {
+		data, err := ioutil.ReadFile(filepath.Join(dst, name))
+		require.NoError(err)
+		assert.Equal(t, name, string(data))
+	}
+}
@@ -2,12 +2,19 @@ package datastore
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
 
 	"github.com/Factom-Asset-Tokens/factom"
+	"golang.org/x/sync/errgroup"
 )
 
 // Generate a set of Data Store Chain Entries for the data read from cData.
@@ -28,6 +35,8 @@ import (
 // The new Data Store chainID is returned, along with the commits and reveals
 // required to create the Data Store Chain, and the totalCost in Entry Credits
 // of creating the Data Store.
+//
+// This is equivalent to GenerateOptions{}.Generate.
 func Generate(ctx context.Context, c *factom.Client, es factom.EsAddress,
 	cData io.Reader, compression *Compression,
 	dataSize uint64, dataHash *factom.Bytes32,
@@ -39,9 +48,90 @@ func Generate(ctx context.Context, c *factom.Client, es factom.EsAddress,
 	totalCost uint,
 	err error) {
 
+	g, err := GenerateOptions{}.Generate(ctx, c, es, cData, compression,
+		dataSize, dataHash, appMetadata, appNamespace...)
+	return g.ChainID, g.TxIDs, g.EntryHashes, g.Commits, g.Reveals,
+		g.TotalCost, err
+}
+
+// GenerateOptions control how Generate creates the Entries of a Data Store.
+type GenerateOptions struct {
+	// Known Data Blocks are reused in the DBI instead of creating new
+	// Data Block Entries with the same Content. If not nil, the newly
+	// generated Data Blocks are added to Known, so that repeated blocks
+	// within the data, or within subsequently generated Data Stores, are
+	// also only created once.
+	Known KnownBlocks
+
+	// Progress, if not nil, is called with StageGenerate as each Entry
+	// is processed.
+	Progress ProgressFunc
+
+	// PoolChainID, if not nil, is the existing Chain on which the DBI and
+	// Data Block Entries are placed, instead of the new Data Store Chain,
+	// such as a Chain shared by all Data Stores of an application. Since
+	// these Entries then do not depend on the creation of the Data Store
+	// Chain, they may be published before, or concurrently with, the
+	// First Entry.
+	PoolChainID *factom.Bytes32
+
+	// Workers is the number of Data Block Entries to marshal, hash and
+	// sign concurrently. If zero, runtime.NumCPU() is used. The
+	// generated Entries do not depend on Workers.
+	Workers int
+}
+
+// Generated holds the Entries required to create a new Data Store.
+type Generated struct {
+	// The new Data Store ChainID.
+	ChainID factom.Bytes32
+
+	// The Transaction IDs, Entry Hashes, commits and reveals of all new
+	// Entries, starting with the First Entry, then the DBI Entries, then
+	// the Data Block Entries.
+	TxIDs       []factom.Bytes32
+	EntryHashes []factom.Bytes32
+	Commits     []factom.Bytes
+	Reveals     []factom.Bytes
+
+	// The total cost in Entry Credits of the new Entries.
+	TotalCost uint
+
+	// The cost in Entry Credits of the reused Known Data Blocks, which
+	// did not need to be created again.
+	SavedCost uint
+}
+
+// Publish g using PublishOptions{}.Publish.
+func (g Generated) Publish(ctx context.Context, c *factom.Client) error {
+	return PublishOptions{}.Publish(ctx, c,
+		g.TxIDs, g.EntryHashes, g.Commits, g.Reveals)
+}
+
+// Generate a set of Data Store Chain Entries for the data read from cData.
+// See Generate for details on the arguments.
+//
+// Data Blocks found in opts.Known are referenced by the DBI but are not
+// included in the returned Entries, so they must already exist, or be
+// published along with the new Data Store.
+func (opts GenerateOptions) Generate(ctx context.Context,
+	c *factom.Client, es factom.EsAddress,
+	cData io.Reader, compression *Compression,
+	dataSize uint64, dataHash *factom.Bytes32,
+	appMetadata json.RawMessage, appNamespace ...factom.Bytes) (
+	Generated, error) {
+
+	var g Generated
+
 	// Compute Data Store ChainID.
 	nameIDs := NameIDs(dataHash, appNamespace...)
-	chainID = factom.ComputeChainID(nameIDs)
+	chainID := factom.ComputeChainID(nameIDs)
+
+	// The ChainID of the DBI and Data Block Entries.
+	entryChainID := chainID
+	if opts.PoolChainID != nil {
+		entryChainID = *opts.PoolChainID
+	}
 
 	// size of the data written to the chain.
 	size := dataSize
@@ -53,65 +143,122 @@ func Generate(ctx context.Context, c *factom.Client, es factom.EsAddress,
 	cDataBuf := bytes.NewBuffer(make([]byte, 0, size))
 	n, err := cDataBuf.ReadFrom(cData)
 	if err != nil {
-		return factom.Bytes32{}, nil, nil, nil, nil, 0, err
+		return Generated{}, err
 	}
 	if n != int64(size) {
-		return factom.Bytes32{}, nil, nil, nil, nil, 0, fmt.Errorf("invalid size")
-	}
-
-	// Compute the expected Data Block Entry Count.
-	dbECount := int(size / factom.EntryMaxDataLen)
-	if size%factom.EntryMaxDataLen > 0 {
-		dbECount++
+		return Generated{}, fmt.Errorf("invalid size")
 	}
 
-	// Compute the expected Data Block Index Entry Count
-	dbiECount := dbECount / MaxLinkedDBIEHashCount
-	if dbECount%MaxLinkedDBIEHashCount > (MaxDBIEHashCount - MaxLinkedDBIEHashCount) {
-		dbiECount++
-	}
+	// Compute the expected Data Block Index and Data Block Entry Counts.
+	dbiECount, dbECount := EntryCounts(size)
 	totalECount := 1 + dbiECount + dbECount
 
 	// We return the commit and reveal data so that users of the library
-	// don't need to regenerate them.
-	txIDs = make([]factom.Bytes32, totalECount)
-	entryHashes = make([]factom.Bytes32, totalECount)
-	commits = make([]factom.Bytes, totalECount)
-	reveals = make([]factom.Bytes, totalECount)
+	// don't need to regenerate them. The First Entry and DBI Entries are
+	// populated by index below, after the new Data Blocks are appended.
+	g.TxIDs = make([]factom.Bytes32, 1+dbiECount, totalECount)
+	g.EntryHashes = make([]factom.Bytes32, 1+dbiECount, totalECount)
+	g.Commits = make([]factom.Bytes, 1+dbiECount, totalECount)
+	g.Reveals = make([]factom.Bytes, 1+dbiECount, totalECount)
 
 	// The raw DBI, the concatenation of all Data Block Entry Hashes.
 	dbi := make([]byte, dbECount*32)
 
-	// Generate all Data Blocks and the DBI
-	for i := 0; i < dbECount; i++ {
-		e := factom.Entry{ChainID: &chainID}
-		e.Content = cDataBuf.Next(factom.EntryMaxDataLen)
+	p := newProgress(opts.Progress, StageGenerate, totalECount, size)
+
+	// Split the data into Data Blocks. The Entries of new Data Blocks are
+	// marshaled, hashed and signed concurrently, but Known Data Blocks
+	// are resolved in order, so that repeated blocks always reference
+	// their first occurrence, and the result does not depend on
+	// opts.Workers.
+	blocks := make([]genBlock, dbECount)
+	for i := range blocks {
+		blocks[i].Content = cDataBuf.Next(factom.EntryMaxDataLen)
+		blocks[i].Ref = i
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.Known != nil {
+		if err := opts.forEach(ctx, len(blocks), func(i int) error {
+			blocks[i].ContentHash = sha256.Sum256(blocks[i].Content)
+			return nil
+		}); err != nil {
+			return Generated{}, err
+		}
+		first := make(map[factom.Bytes32]int, len(blocks))
+		for i := range blocks {
+			b := &blocks[i]
+			if hash, ok := opts.Known[b.ContentHash]; ok {
+				b.Hash = hash
+				b.Ref = -1
+				continue
+			}
+			if j, ok := first[b.ContentHash]; ok {
+				b.Ref = j
+				continue
+			}
+			first[b.ContentHash] = i
+		}
+	}
 
-		reveal, err := e.MarshalBinary()
-		if err != nil {
-			return factom.Bytes32{}, nil, nil, nil, nil, 0, err
+	if err := opts.forEach(ctx, len(blocks), func(i int) error {
+		b := &blocks[i]
+		if b.Ref != i {
+			return nil
 		}
+		e := factom.Entry{ChainID: &entryChainID, Content: b.Content}
+		var err error
+		if b.Reveal, err = e.MarshalBinary(); err != nil {
+			return err
+		}
+		b.Hash = factom.ComputeEntryHash(b.Reveal)
+		b.Commit, b.TxID = factom.GenerateCommit(es, b.Reveal, &b.Hash, false)
+		p.add(1, uint64(len(b.Content)))
+		return nil
+	}); err != nil {
+		return Generated{}, err
+	}
 
-		cost, _ := factom.EntryCost(len(reveal), false)
-		totalCost += uint(cost)
+	// Assemble the new Data Blocks and the DBI in order.
+	for i := range blocks {
+		b := &blocks[i]
+		if b.Ref != i {
+			hash := b.Hash
+			if b.Ref >= 0 {
+				hash = blocks[b.Ref].Hash
+			}
+			cost, _ := factom.EntryCost(
+				factom.EntryHeaderLen+len(b.Content), false)
+			g.SavedCost += uint(cost)
+			copy(dbi[i*32:], hash[:])
+			p.add(1, uint64(len(b.Content)))
+			continue
+		}
 
-		hash := factom.ComputeEntryHash(reveal)
+		cost, _ := factom.EntryCost(len(b.Reveal), false)
+		g.TotalCost += uint(cost)
 
-		commit, txID := factom.GenerateCommit(es, reveal, &hash, false)
+		if opts.Known != nil {
+			opts.Known[b.ContentHash] = b.Hash
+		}
 
-		copy(dbi[i*32:], hash[:])
-		txIDs[1+dbiECount+i] = txID
-		entryHashes[1+dbiECount+i] = hash
-		reveals[1+dbiECount+i] = reveal
-		commits[1+dbiECount+i] = commit
+		copy(dbi[i*32:], b.Hash[:])
+		g.TxIDs = append(g.TxIDs, b.TxID)
+		g.EntryHashes = append(g.EntryHashes, b.Hash)
+		g.Reveals = append(g.Reveals, b.Reveal)
+		g.Commits = append(g.Commits, b.Commit)
 	}
 
 	// nDBHash is the number of trailing Data Block Entry Hashes from the
 	// end of the DBI to include in the last entry. We populate the DBI
 	// Entries in reverse order for creation of the linked list.
-	nDBHash := dbECount % MaxLinkedDBIEHashCount
-	if nDBHash <= 2 {
-		nDBHash += MaxLinkedDBIEHashCount
+	nDBHash := dbECount
+	if dbECount > MaxDBIEHashCount {
+		nDBHash = dbECount % MaxLinkedDBIEHashCount
+		if nDBHash <= MaxDBIEHashCount-MaxLinkedDBIEHashCount {
+			nDBHash += MaxLinkedDBIEHashCount
+		}
 	}
 
 	// dbiI is the starting byte index of the dbi that we will include in
@@ -120,7 +267,7 @@ func Generate(ctx context.Context, c *factom.Client, es factom.EsAddress,
 
 	var dbiStart factom.Bytes32
 	for i := dbiECount; i > 0; i-- {
-		e := factom.Entry{ChainID: &chainID}
+		e := factom.Entry{ChainID: &entryChainID}
 
 		if !dbiStart.IsZero() {
 			e.ExtIDs = []factom.Bytes{dbiStart[:]}
@@ -133,20 +280,21 @@ func Generate(ctx context.Context, c *factom.Client, es factom.EsAddress,
 
 		reveal, err := e.MarshalBinary()
 		if err != nil {
-			return factom.Bytes32{}, nil, nil, nil, nil, 0, err
+			return Generated{}, err
 		}
 
 		cost, _ := factom.EntryCost(len(reveal), false)
-		totalCost += uint(cost)
+		g.TotalCost += uint(cost)
 
 		dbiStart = factom.ComputeEntryHash(reveal)
+		p.add(1, 0)
 
 		commit, txID := factom.GenerateCommit(es, reveal, &dbiStart, false)
 
-		txIDs[i] = txID
-		entryHashes[i] = dbiStart
-		reveals[i] = reveal
-		commits[i] = commit
+		g.TxIDs[i] = txID
+		g.EntryHashes[i] = dbiStart
+		g.Reveals[i] = reveal
+		g.Commits[i] = commit
 	}
 
 	// Initialize Metadata for what will be the first entry.
@@ -165,23 +313,117 @@ func Generate(ctx context.Context, c *factom.Client, es factom.EsAddress,
 	}
 	firstE.Content, err = json.Marshal(m)
 	if err != nil {
-		return factom.Bytes32{}, nil, nil, nil, nil, 0, err
+		return Generated{}, err
 	}
 
 	reveal, err := firstE.MarshalBinary()
 	if err != nil {
-		return factom.Bytes32{}, nil, nil, nil, nil, 0, err
+		return Generated{}, err
 	}
 	hash := factom.ComputeEntryHash(reveal)
 	commit, txID := factom.GenerateCommit(es, reveal, &hash, true)
 
 	cost, _ := factom.EntryCost(len(reveal), true)
-	totalCost += uint(cost)
+	g.TotalCost += uint(cost)
+	p.add(1, 0)
+
+	g.ChainID = chainID
+	g.TxIDs[0] = txID
+	g.EntryHashes[0] = hash
+	g.Commits[0] = commit
+	g.Reveals[0] = reveal
+
+	return g, nil
+}
+
+// genBlock is a Data Block being generated.
+type genBlock struct {
+	Content     factom.Bytes
+	ContentHash factom.Bytes32
+
+	// Ref is the index of the Data Block whose Entry is used for this
+	// Data Block, or -1 if the Hash of a Known Data Block is used.
+	Ref int
 
-	txIDs[0] = txID
-	entryHashes[0] = hash
-	commits[0] = commit
-	reveals[0] = reveal
+	Hash   factom.Bytes32
+	TxID   factom.Bytes32
+	Reveal factom.Bytes
+	Commit factom.Bytes
+}
 
-	return chainID, txIDs, entryHashes, commits, reveals, totalCost, nil
+// forEach calls fn for each index in [0, n) using opts.Workers concurrent
+// goroutines, and returns the first error.
+func (opts GenerateOptions) forEach(ctx context.Context, n int,
+	fn func(int) error) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > n {
+		workers = n
+	}
+	var next int64 = -1
+	g, ctx := errgroup.WithContext(ctx)
+	for w := 0; w < workers; w++ {
+		g.Go(func() error {
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return nil
+				}
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := fn(i); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	return g.Wait()
+}
+
+// ComputeDataHash returns the sha256d hash of data, which is the data hash
+// used by the Data Store protocol.
+func ComputeDataHash(data []byte) factom.Bytes32 {
+	hash := sha256.Sum256(data)
+	return sha256.Sum256(hash[:])
+}
+
+// Compress data using the given format, "gzip", "zlib", or
+// FormatGzipSeekable, and return the compressed data along with the
+// Compression settings to pass to Generate.
+//
+// FormatGzipSeekable uses DefaultFrameSize, or larger frames if the data
+// would otherwise require more than MaxFrames frames.
+//
+// If format is "" or "none", data is returned uncompressed with nil
+// Compression settings.
+func Compress(format string, data []byte) ([]byte, *Compression, error) {
+	format = strings.ToLower(format)
+	cData := bytes.NewBuffer(make([]byte, 0, len(data)))
+	var w io.WriteCloser
+	switch format {
+	case "", "none":
+		return data, nil, nil
+	case "gzip":
+		w = gzip.NewWriter(cData)
+	case "zlib":
+		w = zlib.NewWriter(cData)
+	case FormatGzipSeekable:
+		return CompressSeekable(data, seekableFrameSize(uint64(len(data))))
+	default:
+		return nil, nil, fmt.Errorf("unsupported compression format: %q",
+			format)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	return cData.Bytes(), &Compression{
+		Format: format,
+		Size:   uint64(cData.Len()),
+	}, nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"flag"
 	"fmt"
@@ -16,6 +17,8 @@ import (
 	"github.com/Factom-Asset-Tokens/factom"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
 )
 
 var (
@@ -228,3 +231,231 @@ func txStatus(ctx context.Context, c *factom.Client,
 	}
 	return res.Reveal.Status, nil
 }
+
+func TestGenerateEntryCounts(t *testing.T) {
+	es, err := factom.GenerateEsAddress()
+	require.NoError(t, err)
+	for _, dbECount := range []int{1, 2, 3, MaxLinkedDBIEHashCount,
+		MaxDBIEHashCount, MaxDBIEHashCount + 1,
+		2 * MaxLinkedDBIEHashCount, 2*MaxLinkedDBIEHashCount + 3} {
+		dbECount := dbECount
+		t.Run(fmt.Sprint(dbECount), func(t *testing.T) {
+			require := require.New(t)
+			assert := assert.New(t)
+			data := make([]byte, dbECount*factom.EntryMaxDataLen-5)
+			rand.Read(data)
+			dataHash := ComputeDataHash(data)
+
+			chainID, _, eHashes, _, reveals, _, err := Generate(nil,
+				nil, es, bytes.NewReader(data), nil,
+				uint64(len(data)), &dataHash, nil)
+			require.NoError(err)
+
+			dbiECount, count := EntryCounts(uint64(len(data)))
+			assert.Equal(dbECount, count)
+			if dbECount <= MaxDBIEHashCount {
+				assert.Equal(1, dbiECount)
+			}
+			require.Len(reveals, 1+dbiECount+dbECount)
+
+			entries := make(map[factom.Bytes32]factom.Entry)
+			for i, reveal := range reveals {
+				e := factom.Entry{Hash: &eHashes[i]}
+				require.NoError(e.UnmarshalBinary(reveal))
+				assert.Equal(chainID, *e.ChainID)
+				entries[eHashes[i]] = e
+			}
+
+			m, err := ParseEntry(entries[eHashes[0]])
+			require.NoError(err)
+
+			// Traverse the DBI and reassemble the data.
+			var dbi []byte
+			dbiEHash := *m.DBIStart
+			for i := 0; i < dbiECount; i++ {
+				dbiE, ok := entries[dbiEHash]
+				require.True(ok, "missing DBI Entry")
+				dbi = append(dbi, dbiE.Content...)
+				if i < dbiECount-1 {
+					require.Len(dbiE.ExtIDs, 1)
+					copy(dbiEHash[:], dbiE.ExtIDs[0])
+				} else {
+					assert.Len(dbiE.ExtIDs, 0)
+				}
+			}
+			require.Len(dbi, dbECount*32)
+			var rebuilt []byte
+			for i := 0; i < len(dbi); i += 32 {
+				var hash factom.Bytes32
+				copy(hash[:], dbi[i:])
+				rebuilt = append(rebuilt, entries[hash].Content...)
+			}
+			assert.Equal(data, rebuilt)
+		})
+	}
+}
+
+func TestGeneratePoolChain(t *testing.T) {
+	require := require.New(t)
+
+	s := factomdtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+
+	// The pool chain must already exist.
+	poolE := factom.Entry{ExtIDs: []factom.Bytes{factom.Bytes("pool")}}
+	poolChainID := factom.ComputeChainID(poolE.ExtIDs)
+	poolE.ChainID = &poolChainID
+	pool, err := poolE.MarshalBinary()
+	require.NoError(err)
+	s.AddEBlock(pool)
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	data := make([]byte, 3*factom.EntryMaxDataLen)
+	rand.Read(data)
+	dataHash := ComputeDataHash(data)
+
+	g, err := GenerateOptions{PoolChainID: &poolChainID}.Generate(nil, nil,
+		es, bytes.NewReader(data), nil, uint64(len(data)), &dataHash, nil)
+	require.NoError(err)
+	assert.Equal(t, factom.ComputeChainID(NameIDs(&dataHash)), g.ChainID)
+	for i, reveal := range g.Reveals {
+		var e factom.Entry
+		require.NoError(e.UnmarshalBinary(reveal))
+		if i == 0 {
+			assert.Equal(t, g.ChainID, *e.ChainID)
+		} else {
+			assert.Equal(t, poolChainID, *e.ChainID, i)
+		}
+	}
+
+	// The DBI and Data Blocks may be confirmed before the First Entry.
+	require.NoError(Publish(nil, c, g.TxIDs[1:], g.EntryHashes[1:],
+		g.Commits[1:], g.Reveals[1:]))
+	s.Confirm()
+	require.NoError(Publish(nil, c, g.TxIDs[:1], g.EntryHashes[:1],
+		g.Commits[:1], g.Reveals[:1]))
+	s.Confirm()
+
+	m, err := Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+	buf := bytes.NewBuffer(nil)
+	require.NoError(m.Download(nil, c, buf))
+	assert.Equal(t, data, buf.Bytes())
+
+	a, err := Export(nil, c, &g.ChainID)
+	require.NoError(err)
+	assert.Equal(t, g.Reveals, a.Reveals)
+	receipts, err := DownloadOptions{}.GetReceipts(nil, c, m, true)
+	require.NoError(err)
+	require.NoError(a.VerifyReceipts(receipts))
+	assert.Equal(t, poolChainID, *receipts[1].ChainID())
+}
+
+// repeatedData returns data of dbECount Data Blocks, where every third Data
+// Block repeats an earlier one.
+func repeatedData(dbECount int) []byte {
+	data := make([]byte, dbECount*factom.EntryMaxDataLen-5)
+	rand.Read(data)
+	for i := 3; i < dbECount; i += 3 {
+		copy(data[i*factom.EntryMaxDataLen:(i+1)*factom.EntryMaxDataLen],
+			data[(i/3)*factom.EntryMaxDataLen:])
+	}
+	return data
+}
+
+func TestGenerateWorkers(t *testing.T) {
+	es, err := factom.GenerateEsAddress()
+	require.NoError(t, err)
+	data := repeatedData(2*MaxLinkedDBIEHashCount + 3)
+	dataHash := ComputeDataHash(data)
+
+	// Some Data Blocks are already Known from a previous Data Store.
+	prev := data[5*factom.EntryMaxDataLen : 9*factom.EntryMaxDataLen]
+	prevHash := ComputeDataHash(prev)
+	known := make(KnownBlocks)
+	_, err = GenerateOptions{Known: known}.Generate(nil, nil, es,
+		bytes.NewReader(prev), nil, uint64(len(prev)), &prevHash, nil)
+	require.NoError(t, err)
+
+	var gs []Generated
+	var knowns []KnownBlocks
+	for _, workers := range []int{1, 4, 0} {
+		opts := GenerateOptions{Known: make(KnownBlocks), Workers: workers}
+		for contentHash, hash := range known {
+			opts.Known[contentHash] = hash
+		}
+		g, err := opts.Generate(nil, nil, es, bytes.NewReader(data), nil,
+			uint64(len(data)), &dataHash, nil)
+		require.NoError(t, err)
+		gs = append(gs, g)
+		knowns = append(knowns, opts.Known)
+	}
+
+	g := gs[0]
+	assert.NotZero(t, g.SavedCost)
+	for i, reveal := range g.Reveals {
+		// Each commit is valid for its reveal.
+		commit := g.Commits[i]
+		signedLen := len(commit) - 32 - ed25519.SignatureSize
+		require.True(t, ed25519.Verify(
+			ed25519.PublicKey(commit[signedLen:signedLen+32]),
+			commit[:signedLen], commit[signedLen+32:]), i)
+		assert.Equal(t, factom.Bytes32(sha256.Sum256(commit[:signedLen])),
+			g.TxIDs[i], i)
+		assert.Equal(t, factom.ComputeEntryHash(reveal), g.EntryHashes[i])
+		assert.Equal(t, g.EntryHashes[i][:],
+			[]byte(commit[signedLen-1-32:signedLen-1]), i)
+	}
+
+	// All Entries are identical, except for the commit timestamp salts.
+	for _, other := range gs[1:] {
+		assert.Equal(t, g.ChainID, other.ChainID)
+		assert.Equal(t, g.EntryHashes, other.EntryHashes)
+		assert.Equal(t, g.Reveals, other.Reveals)
+		assert.Equal(t, g.TotalCost, other.TotalCost)
+		assert.Equal(t, g.SavedCost, other.SavedCost)
+		assert.Len(t, other.Commits, len(g.Commits))
+		assert.Len(t, other.TxIDs, len(g.TxIDs))
+	}
+	for _, other := range knowns[1:] {
+		assert.Equal(t, knowns[0], other)
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	es, err := factom.GenerateEsAddress()
+	require.NoError(b, err)
+	data := make([]byte, 1000*factom.EntryMaxDataLen)
+	rand.Read(data)
+	dataHash := ComputeDataHash(data)
+	for _, bench := range []struct {
+		Name string
+		GenerateOptions
+	}{
+		{Name: "serial", GenerateOptions: GenerateOptions{Workers: 1}},
+		{Name: "parallel"},
+		{Name: "serial/known", GenerateOptions: GenerateOptions{
+			Known: make(KnownBlocks), Workers: 1}},
+		{Name: "parallel/known", GenerateOptions: GenerateOptions{
+			Known: make(KnownBlocks)}},
+	} {
+		bench := bench
+		b.Run(bench.Name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				opts := bench.GenerateOptions
+				if opts.Known != nil {
+					// Start with no Known blocks each time.
+					opts.Known = make(KnownBlocks)
+				}
+				if _, err := opts.Generate(nil, nil, es,
+					bytes.NewReader(data), nil, uint64(len(data)),
+					&dataHash, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
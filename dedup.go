@@ -0,0 +1,54 @@
+package datastore
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// KnownBlocks maps the sha256 hash of the Content of existing Data Block
+// Entries to their Entry Hashes, so that GenerateOptions.Generate can reuse
+// them.
+//
+// Since the DBI may reference Entries on any chain, a Data Block is reusable
+// in any Data Store, so long as it is aligned to a multiple of 10240 bytes
+// within the on chain data and has exactly the same Content.
+type KnownBlocks map[factom.Bytes32]factom.Bytes32
+
+// AddReveals adds the Entries among the given reveals that may serve as Data
+// Blocks, such as those returned by Generate for a previously published Data
+// Store. Entries with ExtIDs, such as the First Entry and linked DBI Entries,
+// are ignored.
+func (kb KnownBlocks) AddReveals(reveals ...factom.Bytes) error {
+	for _, reveal := range reveals {
+		var e factom.Entry
+		if err := e.UnmarshalBinary(reveal); err != nil {
+			return err
+		}
+		if len(e.ExtIDs) > 0 || len(e.Content) == 0 {
+			continue
+		}
+		kb[sha256.Sum256(e.Content)] = *e.Hash
+	}
+	return nil
+}
+
+// AddStore downloads all Data Blocks of the Data Store described by m and adds
+// them.
+func (kb KnownBlocks) AddStore(ctx context.Context, c *factom.Client,
+	m Metadata) error {
+	cData, dbi, err := DownloadOptions{}.downloadCData(ctx, c, m)
+	if err != nil {
+		return fmt.Errorf("%v: %w", m.Entry.ChainID, err)
+	}
+	for i, hash := range dbi {
+		block := cData[i*factom.EntryMaxDataLen:]
+		if len(block) > factom.EntryMaxDataLen {
+			block = block[:factom.EntryMaxDataLen]
+		}
+		kb[sha256.Sum256(block)] = hash
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package datastore
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestKnownBlocks(t *testing.T) {
+	require := require.New(t)
+
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+
+	orig, m, reveals := generateTestStore(t, 4*factom.EntryMaxDataLen-10, "")
+	factomd.AddEBlock(reveals...)
+
+	// The new data shares the 2nd and 3rd blocks with orig, and repeats
+	// its own 1st block at the end.
+	data := make([]byte, 5*factom.EntryMaxDataLen)
+	rand.Read(data[:factom.EntryMaxDataLen])
+	copy(data[factom.EntryMaxDataLen:], orig[factom.EntryMaxDataLen:3*factom.EntryMaxDataLen])
+	rand.Read(data[3*factom.EntryMaxDataLen : 4*factom.EntryMaxDataLen])
+	copy(data[4*factom.EntryMaxDataLen:], data[:factom.EntryMaxDataLen])
+	dataHash := ComputeDataHash(data)
+
+	full, err := GenerateOptions{}.Generate(nil, nil, es,
+		bytes.NewReader(data), nil, uint64(len(data)), &dataHash, nil)
+	require.NoError(err)
+	assert.Len(t, full.Reveals, 1+1+5)
+	assert.Zero(t, full.SavedCost)
+
+	for _, kb := range []struct {
+		Add func(KnownBlocks) error
+		N   int
+	}{{
+		Add: func(kb KnownBlocks) error { return kb.AddReveals(reveals...) },
+		// The last DBI Entry has no ExtIDs so it is also a valid
+		// Data Block.
+		N: 4 + 1,
+	}, {
+		Add: func(kb KnownBlocks) error { return kb.AddStore(nil, c, m) },
+		N:   4,
+	}} {
+		known := make(KnownBlocks)
+		require.NoError(kb.Add(known))
+		require.Len(known, kb.N)
+
+		g, err := GenerateOptions{Known: known}.Generate(nil, nil, es,
+			bytes.NewReader(data), nil, uint64(len(data)),
+			&dataHash, nil)
+		require.NoError(err)
+		assert.Equal(t, full.ChainID, g.ChainID)
+
+		// Only the First Entry, the DBI, and 2 new blocks remain.
+		require.Len(g.Reveals, 1+1+2)
+		assert.Len(t, g.Commits, len(g.Reveals))
+		assert.Len(t, g.TxIDs, len(g.Reveals))
+		assert.Len(t, g.EntryHashes, len(g.Reveals))
+		assert.Equal(t, full.TotalCost, g.TotalCost+g.SavedCost)
+		assert.Len(t, known, kb.N+2)
+
+		factomd.AddEntries(g.Reveals...)
+		var first factom.Entry
+		require.NoError(first.UnmarshalBinary(g.Reveals[0]))
+		newM, err := ParseEntry(first)
+		require.NoError(err)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(newM.Download(nil, c, buf))
+		assert.Equal(t, data, buf.Bytes())
+	}
+}
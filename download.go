@@ -0,0 +1,438 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/AdamSLevy/retry"
+	"github.com/Factom-Asset-Tokens/factom"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultDownloadPolicy is a reasonable retry.Policy for
+// DownloadOptions.Policy.
+var DefaultDownloadPolicy retry.Policy = retry.Randomize{Factor: .25,
+	Policy: retry.LimitTotal{Limit: 2 * time.Minute,
+		Policy: retry.Max{Cap: 10 * time.Second,
+			Policy: retry.Exponential{
+				Initial:    100 * time.Millisecond,
+				Multiplier: 2}}}}
+
+// DefaultBatchSize is a reasonable DownloadOptions.BatchSize.
+const DefaultBatchSize = 20
+
+// DownloadOptions control how Data Stores are downloaded.
+type DownloadOptions struct {
+	// Progress, if not nil, is called as the DBI is traversed, with
+	// StageDBI, and as Data Blocks are downloaded, with StageDownload.
+	Progress ProgressFunc
+
+	// Workers is the number of Data Blocks to download concurrently. If
+	// zero, runtime.NumCPU() is used.
+	Workers int
+
+	// Timeout limits the duration of each request to factomd, if not zero.
+	Timeout time.Duration
+
+	// Policy for retrying each failed request for an Entry, if the error
+	// is Retryable. If nil, failed requests are not retried. See
+	// DefaultDownloadPolicy.
+	Policy retry.Policy
+
+	// BatchSize is the maximum number of Data Block Entries to request in
+	// a single JSON-RPC 2.0 batch request. If zero or one, each Entry is
+	// requested individually. If factomd rejects batch requests, each
+	// Entry is requested individually instead. See DefaultBatchSize.
+	BatchSize int
+
+	// Pool, if not nil, is used for all requests instead of the
+	// factom.Client passed to each method, which may then be nil.
+	Pool *Pool
+
+	// entries, if not nil, holds raw Entries by hash, which are used
+	// instead of factomd, such as to verify an Archive offline.
+	entries map[factom.Bytes32]factom.Bytes
+}
+
+// errRequestTimeout is returned when a request exceeds
+// DownloadOptions.Timeout. It is distinct from context.DeadlineExceeded,
+// which stops retry.Run.
+var errRequestTimeout = errors.New("factomd request timed out")
+
+// Retryable returns true if err, returned by a request to factomd, may not
+// occur again if the request is retried. This includes network and HTTP
+// errors, timeouts, and errors reported by factomd, such as for an Entry that
+// it does not have yet.
+//
+// Errors due to invalid data, such as an Entry that does not match its hash,
+// are not Retryable.
+func Retryable(err error) bool {
+	if errors.Is(err, errRequestTimeout) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var jErr jsonrpc2.Error
+	if errors.As(err, &jErr) {
+		switch jErr.Code {
+		case jsonrpc2.ErrorCodeParse,
+			jsonrpc2.ErrorCodeInvalidRequest,
+			jsonrpc2.ErrorCodeMethodNotFound,
+			jsonrpc2.ErrorCodeInvalidParams:
+			return false
+		}
+		return true
+	}
+	// jsonrpc2.Client reports unexpected HTTP statuses this way.
+	return strings.HasPrefix(err.Error(), "http: ")
+}
+
+// getEntry populates e by its Hash, using the Pool, Timeout and retry Policy
+// of opts.
+func (opts DownloadOptions) getEntry(ctx context.Context, c *factom.Client,
+	e *factom.Entry) error {
+	if opts.entries != nil {
+		data, ok := opts.entries[*e.Hash]
+		if !ok {
+			return fmt.Errorf("missing Entry %v", e.Hash)
+		}
+		return e.UnmarshalBinary(data)
+	}
+
+	// Entry.Get leaves e populated after an invalid response, so e must be
+	// reset before each attempt.
+	hash, content := e.Hash, e.Content[:0]
+	getFrom := func(c *factom.Client) error {
+		*e = factom.Entry{Hash: hash, Content: content}
+		reqCtx := ctx
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			reqCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+		err := e.Get(reqCtx, c)
+		if err != nil && ctx.Err() == nil && reqCtx.Err() != nil {
+			return errRequestTimeout
+		}
+		return err
+	}
+	get := func() error { return getFrom(c) }
+	if opts.Pool != nil {
+		get = func() error { return opts.Pool.Do(ctx, getFrom) }
+	}
+	if opts.Policy == nil {
+		return get()
+	}
+	return retry.Run(ctx, opts.Policy, func(err error) error {
+		if err != nil && !Retryable(err) {
+			return retry.ErrorStop(err)
+		}
+		return err
+	}, nil, get)
+}
+
+// GetDBI downloads the Data Block Index of m and returns all Data Block Entry
+// Hashes in order.
+func (opts DownloadOptions) GetDBI(ctx context.Context, c *factom.Client,
+	m Metadata) ([]factom.Bytes32, error) {
+	_, totalDBCount := m.EntryCounts()
+	dbi := make([]factom.Bytes32, 0, totalDBCount)
+	if err := opts.traverseDBI(ctx, c, m, totalDBCount,
+		func(_ int, dbEHash factom.Bytes32) error {
+			dbi = append(dbi, dbEHash)
+			return nil
+		}); err != nil {
+		return nil, err
+	}
+	return dbi, nil
+}
+
+// traverseDBI downloads and validates the DBI linked list of m and calls fn
+// with each of the first n Data Block Entry Hashes, in order.
+func (opts DownloadOptions) traverseDBI(ctx context.Context, c *factom.Client,
+	m Metadata, n int, fn func(i int, dbEHash factom.Bytes32) error) error {
+
+	if m.IsProof() {
+		return ErrNoData
+	}
+
+	_, totalDBCount := m.EntryCounts()
+	p := newProgress(opts.Progress, StageDBI, n, 0)
+
+	// dbiBuf will hold the Content of the current DBI Entry.
+	dbiBuf := bytes.NewBuffer(nil)
+
+	// dbiEHash holds the Entry Hash for the next DBI Entry in the Linked
+	// List.
+	dbiEHash := *m.DBIStart
+
+	for i := 0; i < n; i++ {
+		// If we have no Data Block Hashes to parse, download and
+		// validate the next DBI Entry.
+		if dbiBuf.Len() == 0 {
+			// Download the next DBI Entry.
+			dbiE := factom.Entry{Hash: &dbiEHash}
+			if err := opts.getEntry(ctx, c, &dbiE); err != nil {
+				return err
+			}
+
+			// Ensure there are no incomplete hashes.
+			if len(dbiE.Content)%32 > 0 {
+				return fmt.Errorf("invalid DBI Entry Content")
+			}
+
+			// dbCount is the number of Data Block Hashes in this
+			// DBI Entry.
+			dbCount := len(dbiE.Content) / 32
+
+			// remaining is the number of Data Block Hashes that
+			// still need to be parsed or downloaded.
+			remaining := totalDBCount - i
+
+			// If there are more remaining than can fit in a single
+			// DBI Entry...
+			if remaining > MaxDBIEHashCount {
+
+				// Require exact number of Hashes
+				if dbCount != MaxLinkedDBIEHashCount {
+					return fmt.Errorf("invalid DBI Entry Content")
+				}
+
+				// Require a DBI Entry Link.
+				if len(dbiE.ExtIDs) != 1 ||
+					len(dbiE.ExtIDs[0]) != 32 {
+					return fmt.Errorf(
+						"missing or invalid DBI Entry link")
+				}
+
+				// Parse the next DBI Entry Hash.
+				copy(dbiEHash[:], dbiE.ExtIDs[0])
+			} else if dbCount != remaining {
+				// Otherwise this DBI Entry must include all
+				// remaining DB Hashes.
+				return fmt.Errorf("invalid DBI Entry Content")
+			}
+
+			// Set up the new dbiBuf to parse the DB Hashes from.
+			dbiBuf = bytes.NewBuffer(dbiE.Content)
+		}
+
+		// Parse out the next Data Block Entry Hash.
+		var dbEHash factom.Bytes32
+		dbiBuf.Read(dbEHash[:])
+
+		if err := fn(i, dbEHash); err != nil {
+			return err
+		}
+		p.add(1, 0)
+	}
+	return nil
+}
+
+// dataBlock is a Data Block Entry and its index within the DBI.
+type dataBlock struct {
+	factom.Entry
+	I int
+}
+
+// getDataBlocks concurrently downloads the Data Block Entries sent on dbEs
+// using opts.Workers, and validates that each Data Block Entry fills the
+// capacity of its preallocated Content, or the Entry limit.
+//
+// If opts.BatchSize is greater than one, each worker requests up to BatchSize
+// of the Data Block Entries that are ready at once.
+//
+// Each downloaded Data Block is added to p, and then passed to done, if not
+// nil.
+func (opts DownloadOptions) getDataBlocks(g *errgroup.Group,
+	ctx context.Context, c *factom.Client,
+	dbEs <-chan dataBlock, p *progress, done func(dataBlock) error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	var batchUnsupported int32
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			batch := make([]dataBlock, 0, batchSize)
+			es := make([]*factom.Entry, batchSize)
+			origCaps := make([]int, batchSize)
+			for dbE := range dbEs {
+				// Add any other Data Blocks that are ready, up
+				// to batchSize.
+				batch = append(batch[:0], dbE)
+			fill:
+				for len(batch) < batchSize {
+					select {
+					case dbE, ok := <-dbEs:
+						if !ok {
+							break fill
+						}
+						batch = append(batch, dbE)
+					default:
+						break fill
+					}
+				}
+				for i := range batch {
+					es[i] = &batch[i].Entry
+					origCaps[i] = cap(batch[i].Content)
+				}
+				if err := opts.getEntries(ctx, c, es[:len(batch)],
+					&batchUnsupported); err != nil {
+					return err
+				}
+				for i, dbE := range batch {
+					// Ensure that the Content did not exceed
+					// the original capacity of the
+					// underlying cData slice, and that the
+					// Content is filled to capacity of either
+					// the underlying cData slice, or the
+					// Entry limit.
+					if cap(dbE.Content) != origCaps[i] ||
+						(len(dbE.Content) < factom.EntryMaxDataLen &&
+							len(dbE.Content) != cap(dbE.Content)) {
+						return fmt.Errorf(
+							"invalid Data Block Entry Content")
+					}
+					p.add(1, uint64(len(dbE.Content)))
+					if done == nil {
+						continue
+					}
+					if err := done(dbE); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// Download all Data Block Index and Data Block Entries required to reconstruct
+// the on chain data, and then decompresses the data if necessary before
+// writing it to the given data io.Writer.
+//
+// The Data Block Entries are downloaded concurrently as they are loaded from
+// the DBI.
+//
+// The sha256d hash of the data written to data, is verified.
+func (opts DownloadOptions) Download(ctx context.Context, c *factom.Client,
+	m Metadata, data io.Writer) error {
+
+	cData, _, err := opts.downloadCData(ctx, c, m)
+	if err != nil {
+		return err
+	}
+
+	dataBuf := io.Reader(bytes.NewBuffer(cData))
+
+	// Decompress the data, if necessary
+	if m.Compression != nil {
+		r, err := m.Compression.newReader(dataBuf)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		dataBuf = r
+	}
+
+	// Compute the data hash and write to data.
+	hash := sha256.New()
+	data = io.MultiWriter(hash, data)
+
+	if _, err := io.Copy(data, dataBuf); err != nil {
+		return err
+	}
+
+	// Verify data hash
+	if *m.DataHash != sha256.Sum256(hash.Sum(nil)) {
+		return fmt.Errorf("invalid data hash")
+	}
+
+	return nil
+}
+
+// downloadCData downloads all Data Block Index and Data Block Entries and
+// returns the on chain data, along with the Data Block Entry Hashes.
+//
+// The Data Block Entries are downloaded concurrently as they are loaded from
+// the DBI.
+func (opts DownloadOptions) downloadCData(ctx context.Context,
+	c *factom.Client, m Metadata) ([]byte, []factom.Bytes32, error) {
+
+	// Get the on-chain size.
+	size := m.Size
+	if m.Compression != nil {
+		size = m.Compression.Size
+	}
+
+	// Compute the expected DB Count.
+	_, totalDBCount := EntryCounts(size)
+
+	// cData will contain the on chain data. We preallocate this so that we
+	// can have the Data Block Entries populate it directly as they are
+	// downloaded concurrently.
+	cData := make([]byte, size)
+	dbi := make([]factom.Bytes32, totalDBCount)
+
+	// Pass along the Data Block Entries from the DBI to this channel.
+	dbEs := make(chan dataBlock, totalDBCount)
+
+	// Download and process the Data Block Entries concurrently as they are
+	// parsed from the DBI, which is downloaded below, so both Stages share
+	// the ProgressFunc.
+	opts.Progress = opts.Progress.serialized()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, ctx := errgroup.WithContext(ctx)
+	opts.getDataBlocks(g, ctx, c, dbEs, newProgress(opts.Progress,
+		StageDownload, totalDBCount, size), nil)
+
+	// Download the DBI linked list and populate the Data Block Entry Hashes.
+	err := opts.traverseDBI(ctx, c, m, totalDBCount,
+		func(i int, dbEHash factom.Bytes32) error {
+			dbi[i] = dbEHash
+			dbE := factom.Entry{Hash: &dbi[i]}
+
+			// Set the Content of each Data Block so the Content
+			// will get unmarshalled directly into the proper
+			// location within cData when the Data Blocks are
+			// downloaded concurrently.
+			cDataI := i * factom.EntryMaxDataLen
+			dbE.Content = cData[cDataI:cDataI]
+
+			dbEs <- dataBlock{Entry: dbE, I: i}
+			return nil
+		})
+	close(dbEs)
+
+	// Wait until all Data Block Entries are processed. Prefer any error
+	// from the workers, which cancels ctx and so may cause the DBI
+	// traversal to fail as well.
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cData, dbi, nil
+}
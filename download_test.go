@@ -0,0 +1,193 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/AdamSLevy/retry"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestDownloadOptions(t *testing.T) {
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	_, m, reveals := generateTestStore(t, 8*factom.EntryMaxDataLen, "")
+	factomd.AddEntries(reveals...)
+
+	rawData := factomd.Method("raw-data")
+	policy := retry.LimitAttempts{Limit: 3, Policy: retry.Immediate{}}
+
+	t.Run("retry", func(t *testing.T) {
+		// Fail the first request for every hash.
+		var mu sync.Mutex
+		failed := make(map[string]bool)
+		factomd.Handle("raw-data", func(ctx context.Context,
+			params json.RawMessage) interface{} {
+			mu.Lock()
+			defer mu.Unlock()
+			if !failed[string(params)] {
+				failed[string(params)] = true
+				return jsonrpc2.NewError(5, "Temporary Error", nil)
+			}
+			return rawData(ctx, params)
+		})
+		defer factomd.Handle("raw-data", rawData)
+
+		require.NoError(t, DownloadOptions{Policy: policy}.Download(
+			nil, c, m, ioutil.Discard))
+
+		failed = make(map[string]bool)
+		assert.Error(t, DownloadOptions{}.Download(
+			nil, c, m, ioutil.Discard))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		// Return the wrong Entry for the last Data Block.
+		last := fmt.Sprintf(`{"hash":"%v"}`,
+			factom.ComputeEntryHash(reveals[len(reveals)-1]))
+		wrong, _ := json.Marshal(struct {
+			Hash factom.Bytes32 `json:"hash"`
+		}{factom.ComputeEntryHash(reveals[len(reveals)-2])})
+		factomd.Handle("raw-data", func(ctx context.Context,
+			params json.RawMessage) interface{} {
+			if string(params) == last {
+				params = wrong
+			}
+			return rawData(ctx, params)
+		})
+		defer factomd.Handle("raw-data", rawData)
+
+		calls := factomd.Calls("raw-data")
+		assert.Error(t, DownloadOptions{Policy: policy, Workers: 1}.Download(
+			nil, c, m, ioutil.Discard))
+		// The invalid Entry is not retried.
+		assert.LessOrEqual(t, factomd.Calls("raw-data")-calls, len(reveals))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		var slow int32 = 1
+		factomd.Handle("raw-data", func(ctx context.Context,
+			params json.RawMessage) interface{} {
+			if atomic.CompareAndSwapInt32(&slow, 1, 0) {
+				time.Sleep(100 * time.Millisecond)
+			}
+			return rawData(ctx, params)
+		})
+		defer factomd.Handle("raw-data", rawData)
+
+		require.NoError(t, DownloadOptions{Policy: policy,
+			Timeout: 20 * time.Millisecond}.Download(
+			nil, c, m, ioutil.Discard))
+	})
+
+	t.Run("workers", func(t *testing.T) {
+		var active, max int32
+		factomd.Handle("raw-data", func(ctx context.Context,
+			params json.RawMessage) interface{} {
+			n := atomic.AddInt32(&active, 1)
+			defer atomic.AddInt32(&active, -1)
+			for {
+				prev := atomic.LoadInt32(&max)
+				if n <= prev ||
+					atomic.CompareAndSwapInt32(&max, prev, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return rawData(ctx, params)
+		})
+		defer factomd.Handle("raw-data", rawData)
+
+		require.NoError(t, DownloadOptions{Workers: 1}.Download(
+			nil, c, m, ioutil.Discard))
+		// One worker, plus the DBI traversal.
+		assert.LessOrEqual(t, atomic.LoadInt32(&max), int32(2))
+	})
+}
+
+func TestDownloadWorkerError(t *testing.T) {
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	_, m, reveals := generateTestStore(t,
+		(MaxDBIEHashCount+1)*factom.EntryMaxDataLen, "")
+	factomd.AddEntries(reveals...)
+	defer failFirstDataBlock(t, factomd, m, reveals)()
+
+	err := m.Download(nil, c, ioutil.Discard)
+	var jErr jsonrpc2.Error
+	require.True(t, errors.As(err, &jErr), err)
+	assert.Equal(t, jsonrpc2.ErrorCodeInvalidParams, jErr.Code)
+}
+
+// failFirstDataBlock makes factomd fail the request for the first Data Block
+// of m, which must have at least two DBI Entries, with a non-retryable error.
+// The request for the second DBI Entry is held until the first Data Block has
+// failed and the request is canceled, so that the DBI traversal fails as well.
+// The returned func restores the original raw-data method.
+func failFirstDataBlock(t *testing.T, factomd *factomdtest.Server,
+	m Metadata, reveals []factom.Bytes) func() {
+	entries := make(map[factom.Bytes32]factom.Entry, len(reveals))
+	for _, reveal := range reveals {
+		var e factom.Entry
+		require.NoError(t, e.UnmarshalBinary(reveal))
+		entries[*e.Hash] = e
+	}
+	dbiE := entries[*m.DBIStart]
+	require.Len(t, dbiE.ExtIDs, 1)
+	param := func(hash []byte) string {
+		return fmt.Sprintf(`{"hash":"%x"}`, hash)
+	}
+	firstDB, nextDBI := param(dbiE.Content[:32]), param(dbiE.ExtIDs[0])
+
+	failed := make(chan struct{})
+	var once sync.Once
+	rawData := factomd.Method("raw-data")
+	factomd.Handle("raw-data", func(ctx context.Context,
+		params json.RawMessage) interface{} {
+		switch string(params) {
+		case firstDB:
+			once.Do(func() { close(failed) })
+			return jsonrpc2.NewError(jsonrpc2.ErrorCodeInvalidParams,
+				"Invalid params", nil)
+		case nextDBI:
+			// Respond only once the client has given up.
+			<-failed
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+		return rawData(ctx, params)
+	})
+	return func() { factomd.Handle("raw-data", rawData) }
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, Retryable(errRequestTimeout))
+	assert.True(t, Retryable(fmt.Errorf("wrapped: %w", errRequestTimeout)))
+	assert.True(t, Retryable(fmt.Errorf("http: 503 Service Unavailable")))
+	assert.True(t, Retryable(jsonrpc2.NewError(-32009, "Lookup Error", nil)))
+	assert.False(t, Retryable(jsonrpc2.ErrorInvalidParams(nil)))
+	assert.False(t, Retryable(fmt.Errorf("invalid hash")))
+
+	c := factom.NewClient()
+	c.FactomdServer = "http://localhost:1"
+	err := (&factom.Entry{Hash: new(factom.Bytes32)}).Get(nil, c)
+	assert.True(t, Retryable(err), err)
+}
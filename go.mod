@@ -7,6 +7,7 @@ require (
 	github.com/AdamSLevy/retry v0.0.0-20191017184328-cce921f261f4
 	github.com/Factom-Asset-Tokens/factom v0.0.0-20191107233816-d15165ab9f62
 	github.com/stretchr/testify v1.4.0
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
 	gopkg.in/yaml.v2 v2.2.5 // indirect
 )
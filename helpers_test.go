@@ -0,0 +1,37 @@
+package datastore
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/require"
+)
+
+// generateTestStore generates a Data Store for size random bytes, compressed
+// with the given format, and returns its data and parsed Metadata along with
+// the reveals of all of its Entries.
+func generateTestStore(t *testing.T, size int, format string,
+	namespace ...factom.Bytes) ([]byte, Metadata, []factom.Bytes) {
+	require := require.New(t)
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+
+	data := make([]byte, size)
+	rand.Read(data)
+	dataHash := ComputeDataHash(data)
+	cData, compression, err := Compress(format, data)
+	require.NoError(err)
+
+	_, _, _, _, reveals, _, err := Generate(nil, nil, es,
+		bytes.NewReader(cData), compression, uint64(size), &dataHash,
+		nil, namespace...)
+	require.NoError(err)
+
+	var first factom.Entry
+	require.NoError(first.UnmarshalBinary(reveals[0]))
+	m, err := ParseEntry(first)
+	require.NoError(err)
+	return data, m, reveals
+}
@@ -0,0 +1,182 @@
+// Package index discovers Data Stores on the Factom Blockchain and records
+// them in a local database so that they can be listed and searched.
+//
+// Chain IDs of Data Stores are hashes, so the only way to discover them is to
+// scan the blockchain. An Index scans Directory Blocks for new chains and
+// records those whose First Entry is a valid Data Store First Entry.
+package index
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+// Record describes a Data Store discovered on chain.
+type Record struct {
+	ChainID   factom.Bytes32 `json:"chainid"`
+	DataHash  factom.Bytes32 `json:"data-hash"`
+	Namespace []factom.Bytes `json:"namespace,omitempty"`
+
+	Size        uint64                 `json:"size"`
+	Compression *datastore.Compression `json:"compression,omitempty"`
+	AppMetadata json.RawMessage        `json:"metadata,omitempty"`
+
+	// Proof is true for proof of existence records, which have no data
+	// on chain.
+	Proof bool `json:"proof,omitempty"`
+
+	// The Directory Block Height of the First Entry.
+	Height uint32 `json:"height"`
+}
+
+// NewRecord returns the Record for a Data Store with Metadata m, created at
+// the given Directory Block height.
+func NewRecord(m datastore.Metadata, height uint32) Record {
+	return Record{
+		ChainID:     *m.Entry.ChainID,
+		DataHash:    *m.DataHash,
+		Namespace:   m.Namespace(),
+		Size:        m.Size,
+		Compression: m.Compression,
+		AppMetadata: m.AppMetadata,
+		Proof:       m.IsProof(),
+		Height:      height,
+	}
+}
+
+var (
+	bucketRecords = []byte("records")
+	bucketState   = []byte("state")
+	keyNextHeight = []byte("next-height")
+)
+
+// Index is a database of discovered Data Stores.
+type Index struct {
+	db *bolt.DB
+
+	// ParseOptions are used to validate the First Entry of each new
+	// chain. Data Stores that fail validation are not recorded.
+	ParseOptions datastore.ParseOptions
+
+	// Workers is the number of EBlocks within a Directory Block that are
+	// scanned concurrently. If zero, DefaultWorkers is used.
+	Workers int
+
+	// ErrorLog receives the retryable errors encountered by Follow. If
+	// nil, the log package's standard logger is used.
+	ErrorLog *log.Logger
+}
+
+// DefaultWorkers is the default value for Index.Workers.
+const DefaultWorkers = 8
+
+// Open the Index database at path, creating it if it does not exist.
+func Open(path string) (*Index, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketRecords, bucketState} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Close the Index database.
+func (idx *Index) Close() error {
+	return idx.db.Close()
+}
+
+// NextHeight returns the next Directory Block height to be scanned, which is
+// one greater than the last height that was completely scanned.
+func (idx *Index) NextHeight() (uint32, error) {
+	var height uint32
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketState).Get(keyNextHeight)
+		if len(v) == 4 {
+			height = binary.BigEndian.Uint32(v)
+		}
+		return nil
+	})
+	return height, err
+}
+
+// Get the Record for chainID. If no such Data Store has been recorded, false
+// is returned.
+func (idx *Index) Get(chainID *factom.Bytes32) (Record, bool, error) {
+	var r Record
+	var found bool
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucketRecords).Get(chainID[:])
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &r)
+	})
+	return r, found, err
+}
+
+// ForEach calls fn for each Record in order of Chain ID, until fn returns
+// false or an error.
+func (idx *Index) ForEach(fn func(Record) (bool, error)) error {
+	return idx.db.View(func(tx *bolt.Tx) error {
+		cur := tx.Bucket(bucketRecords).Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			var r Record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("%x: %w", k, err)
+			}
+			ok, err := fn(r)
+			if err != nil || !ok {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Count returns the number of recorded Data Stores.
+func (idx *Index) Count() (int, error) {
+	var n int
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketRecords).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+// Put saves the records and sets the next height to scan, atomically.
+func (idx *Index) Put(nextHeight uint32, records ...Record) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRecords)
+		for _, r := range records {
+			v, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(r.ChainID[:], v); err != nil {
+				return err
+			}
+		}
+		var height [4]byte
+		binary.BigEndian.PutUint32(height[:], nextHeight)
+		return tx.Bucket(bucketState).Put(keyNextHeight, height[:])
+	})
+}
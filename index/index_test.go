@@ -0,0 +1,100 @@
+package index
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+// openTestIndex opens a new Index in a temporary directory and returns a
+// function that closes and removes it.
+func openTestIndex(t *testing.T) (*Index, func()) {
+	dir, err := ioutil.TempDir("", "fds-index")
+	require.NoError(t, err)
+	idx, err := Open(filepath.Join(dir, "index.db"))
+	require.NoError(t, err)
+	return idx, func() {
+		idx.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	idx, cleanup := openTestIndex(t)
+	defer cleanup()
+
+	next, err := idx.NextHeight()
+	require.NoError(err)
+	assert.EqualValues(0, next)
+
+	dataHash := factom.Bytes32{1}
+	nameIDs := datastore.NameIDs(&dataHash, factom.Bytes("app"))
+	chainID := factom.ComputeChainID(nameIDs)
+	m, err := datastore.ParseEntry(factom.Entry{
+		ChainID: &chainID,
+		ExtIDs:  nameIDs,
+		Content: factom.Bytes(`{"data-store":"1.0","size":10,` +
+			`"dbi-start":"0000000000000000000000000000000000000000000000000000000000000001",` +
+			`"compression":{"format":"gzip","size":5},` +
+			`"metadata":{"filename":"a.txt"}}`),
+	})
+	require.NoError(err)
+
+	r := NewRecord(m, 100)
+	assert.Equal(chainID, r.ChainID)
+	assert.Equal(dataHash, r.DataHash)
+	assert.Equal([]factom.Bytes{factom.Bytes("app")}, r.Namespace)
+	assert.EqualValues(5, r.Compression.Size)
+	assert.Equal(json.RawMessage(`{"filename":"a.txt"}`), r.AppMetadata)
+	assert.False(r.Proof)
+
+	proofHash := factom.Bytes32{2}
+	proofIDs := datastore.NameIDs(&proofHash)
+	proofID := factom.ComputeChainID(proofIDs)
+	m, err = datastore.ParseEntry(factom.Entry{
+		ChainID: &proofID,
+		ExtIDs:  proofIDs,
+		Content: factom.Bytes(`{"data-store":"1.0","size":10,"proof":true}`),
+	})
+	require.NoError(err)
+	assert.True(NewRecord(m, 100).Proof)
+
+	require.NoError(idx.Put(101, r))
+	next, err = idx.NextHeight()
+	require.NoError(err)
+	assert.EqualValues(101, next)
+
+	got, found, err := idx.Get(&chainID)
+	require.NoError(err)
+	require.True(found)
+	assert.Equal(r, got)
+
+	_, found, err = idx.Get(&dataHash)
+	require.NoError(err)
+	assert.False(found)
+
+	r2 := r
+	r2.ChainID = factom.Bytes32{2}
+	require.NoError(idx.Put(102, r2))
+	n, err := idx.Count()
+	require.NoError(err)
+	assert.Equal(2, n)
+
+	var records []Record
+	require.NoError(idx.ForEach(func(r Record) (bool, error) {
+		records = append(records, r)
+		return true, nil
+	}))
+	assert.Equal([]Record{r2, r}, records)
+}
@@ -0,0 +1,169 @@
+package index
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Query selects Records from an Index. The zero value of each field matches
+// all Records.
+type Query struct {
+	// Namespace matches Records whose Namespace begins with these IDs.
+	Namespace []factom.Bytes
+
+	// MinSize and MaxSize match the uncompressed data size, inclusive.
+	// A MaxSize of zero is unbounded.
+	MinSize, MaxSize uint64
+
+	// MinHeight and MaxHeight match the Directory Block height at which
+	// the Data Store was created, inclusive. A MaxHeight of zero is
+	// unbounded.
+	MinHeight, MaxHeight uint32
+
+	// Compression matches the compression format. Use "none" to match
+	// Data Stores without compression.
+	Compression string
+
+	// Proof, if not nil, matches Records that are, or are not, proof of
+	// existence records.
+	Proof *bool
+
+	// Metadata matches Records whose AppMetadata has a field at each
+	// path equal to the given value. Paths are dot separated object keys
+	// or array indexes, such as "author.name" or "tags.0". Values are
+	// compared as JSON, so any value that marshals to equivalent JSON
+	// matches.
+	Metadata map[string]interface{}
+
+	// Limit the number of Records returned. Zero is unlimited.
+	Limit int
+}
+
+// Query returns all Records matching q, in order of Chain ID.
+func (idx *Index) Query(q Query) ([]Record, error) {
+	metadata, err := q.normalizeMetadata()
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	err = idx.ForEach(func(r Record) (bool, error) {
+		if q.match(r, metadata) {
+			records = append(records, r)
+		}
+		return q.Limit == 0 || len(records) < q.Limit, nil
+	})
+	return records, err
+}
+
+// Match returns true if r matches q.
+func (q Query) Match(r Record) bool {
+	metadata, err := q.normalizeMetadata()
+	if err != nil {
+		return false
+	}
+	return q.match(r, metadata)
+}
+
+func (q Query) match(r Record, metadata map[string]interface{}) bool {
+	if len(r.Namespace) < len(q.Namespace) {
+		return false
+	}
+	for i, id := range q.Namespace {
+		if !bytes.Equal(id, r.Namespace[i]) {
+			return false
+		}
+	}
+
+	if r.Size < q.MinSize || (q.MaxSize > 0 && r.Size > q.MaxSize) {
+		return false
+	}
+	if r.Height < q.MinHeight || (q.MaxHeight > 0 && r.Height > q.MaxHeight) {
+		return false
+	}
+
+	switch q.Compression {
+	case "":
+	case "none":
+		if r.Compression != nil {
+			return false
+		}
+	default:
+		if r.Compression == nil ||
+			!strings.EqualFold(r.Compression.Format, q.Compression) {
+			return false
+		}
+	}
+
+	if q.Proof != nil && r.Proof != *q.Proof {
+		return false
+	}
+
+	if len(metadata) == 0 {
+		return true
+	}
+	var appMetadata interface{}
+	if json.Unmarshal(r.AppMetadata, &appMetadata) != nil {
+		return false
+	}
+	for path, want := range metadata {
+		got, ok := lookupPath(appMetadata, path)
+		if !ok || !reflect.DeepEqual(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeMetadata round trips the q.Metadata values through JSON so that
+// they can be compared with values unmarshaled from AppMetadata.
+func (q Query) normalizeMetadata() (map[string]interface{}, error) {
+	metadata := make(map[string]interface{}, len(q.Metadata))
+	for path, v := range q.Metadata {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var norm interface{}
+		if err := json.Unmarshal(data, &norm); err != nil {
+			return nil, err
+		}
+		metadata[path] = norm
+	}
+	return metadata, nil
+}
+
+// lookupPath returns the value at the dot separated path within v.
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch obj := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = obj[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(obj) {
+				return nil, false
+			}
+			v = obj[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// ChainIDs returns the Chain IDs of records.
+func ChainIDs(records []Record) []factom.Bytes32 {
+	chainIDs := make([]factom.Bytes32, len(records))
+	for i, r := range records {
+		chainIDs[i] = r.ChainID
+	}
+	return chainIDs
+}
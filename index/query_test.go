@@ -0,0 +1,78 @@
+package index
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+func TestQuery(t *testing.T) {
+	idx, cleanup := openTestIndex(t)
+	defer cleanup()
+
+	records := []Record{{
+		ChainID:     factom.Bytes32{1},
+		Namespace:   []factom.Bytes{factom.Bytes("app"), factom.Bytes("a")},
+		Size:        100,
+		Height:      10,
+		AppMetadata: json.RawMessage(`{"filename":"a.txt","tags":["x"],"v":1}`),
+	}, {
+		ChainID:     factom.Bytes32{2},
+		Namespace:   []factom.Bytes{factom.Bytes("app")},
+		Size:        1000,
+		Height:      20,
+		Compression: &datastore.Compression{Format: "gzip", Size: 500},
+		AppMetadata: json.RawMessage(`{"filename":"b.txt","author":{"name":"x"}}`),
+	}, {
+		ChainID: factom.Bytes32{3},
+		Size:    10000,
+		Height:  30,
+		Proof:   true,
+	}}
+	proof, notProof := true, false
+	require.NoError(t, idx.Put(31, records...))
+
+	for _, test := range []struct {
+		Name  string
+		Query Query
+		Match []int
+	}{
+		{"all", Query{}, []int{0, 1, 2}},
+		{"limit", Query{Limit: 2}, []int{0, 1}},
+		{"namespace", Query{Namespace: []factom.Bytes{
+			factom.Bytes("app")}}, []int{0, 1}},
+		{"namespace/exact", Query{Namespace: []factom.Bytes{
+			factom.Bytes("app"), factom.Bytes("a")}}, []int{0}},
+		{"size", Query{MinSize: 100, MaxSize: 1000}, []int{0, 1}},
+		{"size/min", Query{MinSize: 101}, []int{1, 2}},
+		{"height", Query{MinHeight: 15, MaxHeight: 30}, []int{1, 2}},
+		{"compression", Query{Compression: "GZIP"}, []int{1}},
+		{"compression/none", Query{Compression: "none"}, []int{0, 2}},
+		{"proof", Query{Proof: &proof}, []int{2}},
+		{"proof/false", Query{Proof: &notProof}, []int{0, 1}},
+		{"metadata", Query{Metadata: map[string]interface{}{
+			"filename": "a.txt"}}, []int{0}},
+		{"metadata/number", Query{Metadata: map[string]interface{}{
+			"v": 1.0}}, []int{0}},
+		{"metadata/nested", Query{Metadata: map[string]interface{}{
+			"author.name": "x"}}, []int{1}},
+		{"metadata/array", Query{Metadata: map[string]interface{}{
+			"tags.0": "x"}}, []int{0}},
+		{"metadata/missing", Query{Metadata: map[string]interface{}{
+			"tags.1": "x"}}, nil},
+	} {
+		results, err := idx.Query(test.Query)
+		require.NoError(t, err, test.Name)
+		var expected []Record
+		for _, i := range test.Match {
+			expected = append(expected, records[i])
+			assert.True(t, test.Query.Match(records[i]), test.Name)
+		}
+		assert.Equal(t, expected, results, test.Name)
+	}
+}
@@ -0,0 +1,188 @@
+package index
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+// Sync scans the Directory Blocks from start through end, inclusive, and
+// records all new Data Stores.
+//
+// Each Directory Block is recorded atomically along with the next height to
+// scan, so Sync may be interrupted and later resumed from idx.NextHeight().
+func (idx *Index) Sync(ctx context.Context, c *factom.Client,
+	start, end uint32) error {
+	for height := start; height <= end; height++ {
+		records, err := idx.scan(ctx, c, height)
+		if err != nil {
+			return err
+		}
+		if err := idx.Put(height+1, records...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Follow scans from idx.NextHeight(), or start if it is greater, to the
+// latest Directory Block for which factomd has all Entry Blocks, and then
+// polls for new Directory Blocks every poll interval, until ctx is done.
+//
+// Errors accepted by datastore.Retryable, such as network errors, are logged
+// to idx.ErrorLog and the scan is retried at the next poll interval. Any other
+// error is returned.
+func (idx *Index) Follow(ctx context.Context, c *factom.Client,
+	start uint32, poll time.Duration) error {
+
+	tkr := time.NewTicker(poll)
+	defer tkr.Stop()
+	for {
+		if err := idx.follow(ctx, c, start); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if !datastore.Retryable(err) {
+				return err
+			}
+			idx.logf("index: follow: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tkr.C:
+		}
+	}
+}
+
+// follow syncs from idx.NextHeight(), or start if it is greater, to the latest
+// Directory Block for which factomd has all Entry Blocks.
+func (idx *Index) follow(ctx context.Context, c *factom.Client,
+	start uint32) error {
+	next, err := idx.NextHeight()
+	if err != nil {
+		return err
+	}
+	if start > next {
+		next = start
+	}
+
+	var heights factom.Heights
+	if err := heights.Get(ctx, c); err != nil {
+		return err
+	}
+	if next > heights.EntryBlock {
+		return nil
+	}
+	return idx.Sync(ctx, c, next, heights.EntryBlock)
+}
+
+// logf logs to idx.ErrorLog, or the standard logger if it is nil.
+func (idx *Index) logf(format string, v ...interface{}) {
+	if idx.ErrorLog != nil {
+		idx.ErrorLog.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// special Chain IDs are the Admin, Entry Credit, and Factoid Block chains,
+// which never contain Entries.
+var special = map[factom.Bytes32]struct{}{
+	factom.ABlockChainID():  {},
+	factom.ECBlockChainID(): {},
+	factom.FBlockChainID():  {},
+}
+
+// scan returns the Records for all Data Stores created at height.
+func (idx *Index) scan(ctx context.Context, c *factom.Client,
+	height uint32) ([]Record, error) {
+
+	db := factom.DBlock{Height: height}
+	if err := db.Get(ctx, c); err != nil {
+		return nil, err
+	}
+
+	ebs := make(chan factom.EBlock)
+	workers := idx.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	found := make([][]Record, workers)
+	g, gctx := errgroup.WithContext(ctx)
+	for i := range found {
+		i := i
+		g.Go(func() error {
+			for eb := range ebs {
+				r, ok, err := idx.scanEBlock(gctx, c, eb)
+				if err != nil {
+					return err
+				}
+				if ok {
+					found[i] = append(found[i], r)
+				}
+			}
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		defer close(ebs)
+		for _, eb := range db.EBlocks {
+			if _, ok := special[*eb.ChainID]; ok {
+				continue
+			}
+			select {
+			case ebs <- eb:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		}
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, r := range found {
+		records = append(records, r...)
+	}
+	return records, nil
+}
+
+// scanEBlock returns the Record for eb's chain if eb is the first EBlock of a
+// new Data Store Chain.
+func (idx *Index) scanEBlock(ctx context.Context, c *factom.Client,
+	eb factom.EBlock) (Record, bool, error) {
+	if err := eb.Get(ctx, c); err != nil {
+		return Record{}, false, err
+	}
+	if !eb.IsFirst() {
+		return Record{}, false, nil
+	}
+
+	e := eb.Entries[0]
+	if err := e.Get(ctx, c); err != nil {
+		return Record{}, false, err
+	}
+
+	// Most chains are not Data Stores, so avoid parsing the JSON unless
+	// the ExtIDs declare the protocol.
+	if len(e.ExtIDs) < 2 || string(e.ExtIDs[0]) != datastore.Protocol {
+		return Record{}, false, nil
+	}
+
+	m, err := idx.ParseOptions.ParseEntry(e)
+	if err != nil {
+		return Record{}, false, nil
+	}
+	return NewRecord(m, eb.Height), true, nil
+}
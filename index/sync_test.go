@@ -0,0 +1,95 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds"
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestFollow(t *testing.T) {
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(t, err)
+	data := make([]byte, 100)
+	rand.Read(data)
+	dataHash := datastore.ComputeDataHash(data)
+	chainID, _, _, _, reveals, _, err := datastore.Generate(nil, nil, es,
+		bytes.NewReader(data), nil, uint64(len(data)), &dataHash, nil)
+	require.NoError(t, err)
+	_, height := factomd.AddEBlock(reveals...)
+
+	idx, cleanup := openTestIndex(t)
+	defer cleanup()
+	var logs bytes.Buffer
+	idx.ErrorLog = log.New(&logs, "", 0)
+
+	t.Run("retryable", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		// Fail the first request with a retryable error.
+		var mu sync.Mutex
+		var failed bool
+		factomd.Handle("heights", func(_ context.Context,
+			_ json.RawMessage) interface{} {
+			mu.Lock()
+			defer mu.Unlock()
+			if !failed {
+				failed = true
+				return jsonrpc2.NewError(5, "Temporary Error", nil)
+			}
+			return factom.Heights{EntryBlock: height}
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errc := make(chan error, 1)
+		go func() {
+			errc <- idx.Follow(ctx, c, 0, 10*time.Millisecond)
+		}()
+
+		require.Eventually(func() bool {
+			next, err := idx.NextHeight()
+			return err == nil && next == height+1
+		}, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		assert.Equal(context.Canceled, <-errc)
+		assert.Contains(logs.String(), "Temporary Error")
+
+		_, found, err := idx.Get(&chainID)
+		require.NoError(err)
+		assert.True(found)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		require := require.New(t)
+		assert := assert.New(t)
+
+		factomd.Handle("heights", func(_ context.Context,
+			_ json.RawMessage) interface{} {
+			return jsonrpc2.NewError(jsonrpc2.ErrorCodeInvalidParams,
+				"Invalid Params", nil)
+		})
+		err := idx.Follow(context.Background(), c, 0, time.Hour)
+		var jErr jsonrpc2.Error
+		require.True(errors.As(err, &jErr))
+		assert.Equal(jsonrpc2.ErrorCodeInvalidParams, jErr.Code)
+	})
+}
@@ -0,0 +1,660 @@
+// Package factomdtest provides an in-memory implementation of the subset of
+// the factomd API used by this module, for testing without a network.
+package factomdtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// ErrorNotFound stands in for factomd's "Lookup Error", whose code is in the
+// range reserved by JSON-RPC 2.0 and so may not be returned by a MethodFunc.
+var ErrorNotFound = jsonrpc2.NewError(1, "Lookup Error", "Not found")
+
+// ErrorMissingChainHead stands in for factomd's "Missing Chain Head" error.
+var ErrorMissingChainHead = jsonrpc2.NewError(2, "Missing Chain Head", nil)
+
+// Genesis is the timestamp of the Directory Block at height 0. Each following
+// Directory Block is ten minutes later.
+var Genesis = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Server is an in-memory factomd API.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	methods jsonrpc2.MethodMap
+	calls   map[string]int
+
+	// Number of HTTP requests, and whether batch requests are rejected.
+	requests      int
+	rejectBatches bool
+
+	// Raw Entries and EBlocks by hash.
+	data map[factom.Bytes32]factom.Bytes
+
+	chains map[factom.Bytes32]*chain
+	height uint32
+
+	// Raw DBlocks and their KeyMRs by height, each containing a single
+	// EBlock.
+	dblocks             []factom.Bytes
+	dbKeyMRs            []factom.Bytes32
+	dbKeyMR, dbFullHash factom.Bytes32
+	// EBlock KeyMRs by the Entry Hashes that they contain.
+	entryEBlocks map[factom.Bytes32]factom.Bytes32
+
+	// Committed Entry Hashes by Transaction ID.
+	commits map[factom.Bytes32]factom.Bytes32
+	// Revealed Entries not yet in an EBlock, in order.
+	pending []factom.Bytes
+	// Entry Hashes of all revealed and confirmed Entries.
+	revealed, confirmed map[factom.Bytes32]bool
+}
+
+type chain struct {
+	Head     factom.Bytes32
+	FullHash factom.Bytes32
+	Sequence uint32
+}
+
+// NewServer starts and returns a new Server. The caller should call Close
+// when finished.
+func NewServer() *Server {
+	s := &Server{
+		calls:  make(map[string]int),
+		data:   make(map[factom.Bytes32]factom.Bytes),
+		chains: make(map[factom.Bytes32]*chain),
+
+		entryEBlocks: make(map[factom.Bytes32]factom.Bytes32),
+
+		commits:   make(map[factom.Bytes32]factom.Bytes32),
+		revealed:  make(map[factom.Bytes32]bool),
+		confirmed: make(map[factom.Bytes32]bool),
+	}
+	s.methods = jsonrpc2.MethodMap{
+		"raw-data":         s.rawData,
+		"dblock-by-height": s.dblockByHeight,
+		"chain-head":       s.chainHead,
+		"commit-entry":     s.commit,
+		"commit-chain":     s.commit,
+		"reveal-entry":     s.reveal,
+		"pending-entries":  s.pendingEntries,
+		"receipt":          s.receipt,
+		"ack":              s.ack,
+	}
+	lgr := log.New(discard{}, "", 0)
+	s.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			batch := isBatch(r)
+			s.mu.Lock()
+			s.requests++
+			if s.rejectBatches && batch {
+				s.mu.Unlock()
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"jsonrpc":"2.0","id":null,` +
+					`"error":{"code":-32600,"message":"Invalid Request"}}`))
+				return
+			}
+			methods := make(jsonrpc2.MethodMap, len(s.methods))
+			for name, method := range s.methods {
+				methods[name] = s.count(name, method)
+			}
+			s.mu.Unlock()
+			jsonrpc2.HTTPRequestHandler(methods, lgr)(w, r)
+		}))
+	return s
+}
+
+// isBatch returns true if the body of r is a JSON array, and restores the
+// body.
+func isBatch(r *http.Request) bool {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	body = bytes.TrimSpace(body)
+	return len(body) > 0 && body[0] == '['
+}
+
+// Requests returns the number of HTTP requests made to s.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// RejectBatches sets whether s rejects JSON-RPC 2.0 batch requests, as
+// factomd does.
+func (s *Server) RejectBatches(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectBatches = reject
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+
+// Client returns a new factom.Client that uses s as its factomd.
+func (s *Server) Client() *factom.Client {
+	c := factom.NewClient()
+	c.FactomdServer = s.URL
+	return c
+}
+
+// Handle adds or replaces the method with the given name.
+func (s *Server) Handle(name string, method jsonrpc2.MethodFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[name] = method
+}
+
+// Method returns the method with the given name, so that it may be wrapped
+// and passed to Handle.
+func (s *Server) Method(name string) jsonrpc2.MethodFunc {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.methods[name]
+}
+
+func (s *Server) count(name string, method jsonrpc2.MethodFunc) jsonrpc2.MethodFunc {
+	return func(ctx context.Context, params json.RawMessage) interface{} {
+		s.mu.Lock()
+		s.calls[name]++
+		s.mu.Unlock()
+		return method(ctx, params)
+	}
+}
+
+// Calls returns the number of calls to the named method.
+func (s *Server) Calls(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[name]
+}
+
+// AddEntries makes the given raw Entries available by Entry Hash, without
+// adding them to any EBlock.
+func (s *Server) AddEntries(reveals ...factom.Bytes) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, reveal := range reveals {
+		s.data[factom.ComputeEntryHash(reveal)] = reveal
+	}
+}
+
+// RemoveEntries removes the Entries with the given hashes.
+func (s *Server) RemoveEntries(hashes ...factom.Bytes32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, hash := range hashes {
+		delete(s.data, hash)
+	}
+}
+
+// AddEBlock adds the given raw Entries, which must all have the same Chain ID,
+// and a new EBlock containing them at the next height, which becomes the new
+// chain head. A new DBlock containing only the EBlock is added at that height,
+// with a timestamp relative to Genesis. The EBlock KeyMR and height are
+// returned.
+func (s *Server) AddEBlock(reveals ...factom.Bytes) (factom.Bytes32, uint32) {
+	s.AddEntries(reveals...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var chainID factom.Bytes32
+	copy(chainID[:], reveals[0][1:])
+	ch, ok := s.chains[chainID]
+	if !ok {
+		ch = new(chain)
+		s.chains[chainID] = ch
+	} else {
+		ch.Sequence++
+	}
+
+	// Entry hashes followed by a single minute marker.
+	objects := make([][]byte, len(reveals)+1)
+	for i, reveal := range reveals {
+		hash := factom.ComputeEntryHash(reveal)
+		objects[i] = hash[:]
+	}
+	objects[len(reveals)] = (&factom.Bytes32{31: 1})[:]
+	bodyMR, err := factom.ComputeEBlockBodyMR(objects)
+	if err != nil {
+		panic(err)
+	}
+
+	height := s.height
+	s.height++
+
+	data := make([]byte, factom.EBlockHeaderLen, factom.EBlockHeaderLen+
+		len(objects)*factom.EBlockObjectLen)
+	i := copy(data, chainID[:])
+	i += copy(data[i:], bodyMR[:])
+	i += copy(data[i:], ch.Head[:])
+	i += copy(data[i:], ch.FullHash[:])
+	binary.BigEndian.PutUint32(data[i:], ch.Sequence)
+	binary.BigEndian.PutUint32(data[i+4:], height)
+	binary.BigEndian.PutUint32(data[i+8:], uint32(len(objects)))
+	for _, obj := range objects {
+		data = append(data, obj...)
+	}
+
+	for _, reveal := range reveals {
+		s.confirmed[factom.ComputeEntryHash(reveal)] = true
+	}
+
+	headerHash := factom.ComputeEBlockHeaderHash(data)
+	keyMR := factom.ComputeKeyMR(&headerHash, &bodyMR)
+	ch.Head = keyMR
+	ch.FullHash = factom.ComputeFullHash(data)
+	s.data[keyMR] = data
+	for _, reveal := range reveals {
+		s.entryEBlocks[factom.ComputeEntryHash(reveal)] = keyMR
+	}
+	s.addDBlock(height, chainID, keyMR)
+
+	return keyMR, height
+}
+
+// addDBlock adds the DBlock at height containing the Admin, EC and FCT Blocks,
+// which are empty, and the EBlock with the given chainID and keyMR.
+func (s *Server) addDBlock(height uint32, chainID, keyMR factom.Bytes32) {
+	aID, ecID, fctID := factom.ABlockChainID(), factom.ECBlockChainID(),
+		factom.FBlockChainID()
+	var empty factom.Bytes32
+	db := factom.DBlock{
+		KeyMR:        &empty,
+		FullHash:     &empty,
+		PrevKeyMR:    &s.dbKeyMR,
+		PrevFullHash: &s.dbFullHash,
+		Height:       height,
+		Timestamp:    Genesis.Add(time.Duration(height) * 10 * time.Minute),
+		EBlocks: []factom.EBlock{
+			{ChainID: &aID, KeyMR: &empty},
+			{ChainID: &ecID, KeyMR: &empty},
+			{ChainID: &fctID, KeyMR: &empty},
+			{ChainID: &chainID, KeyMR: &keyMR},
+		},
+	}
+	elements := make([][]byte, len(db.EBlocks))
+	for i, eb := range db.EBlocks {
+		elements[i] = append(eb.ChainID[:len(eb.ChainID):len(eb.ChainID)],
+			eb.KeyMR[:]...)
+	}
+	bodyMR, err := factom.ComputeDBlockBodyMR(elements)
+	if err != nil {
+		panic(err)
+	}
+	db.BodyMR = &bodyMR
+	data, err := db.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+
+	headerHash := factom.ComputeDBlockHeaderHash(data)
+	s.dbKeyMR = factom.ComputeKeyMR(&headerHash, &bodyMR)
+	s.dbFullHash = factom.ComputeFullHash(data)
+	s.dblocks = append(s.dblocks, data)
+	s.dbKeyMRs = append(s.dbKeyMRs, s.dbKeyMR)
+	s.data[s.dbKeyMR] = data
+}
+
+func (s *Server) rawData(_ context.Context, params json.RawMessage) interface{} {
+	var p struct {
+		Hash *factom.Bytes32 `json:"hash"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.Hash == nil {
+		return jsonrpc2.ErrorInvalidParams(nil)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[*p.Hash]
+	if !ok {
+		return ErrorNotFound
+	}
+	return struct {
+		Data factom.Bytes `json:"data"`
+	}{data}
+}
+
+func (s *Server) dblockByHeight(_ context.Context,
+	params json.RawMessage) interface{} {
+	var p struct {
+		Height *uint32 `json:"height"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.Height == nil {
+		return jsonrpc2.ErrorInvalidParams(nil)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if int(*p.Height) >= len(s.dblocks) {
+		return ErrorNotFound
+	}
+	type dblock struct {
+		KeyMR factom.Bytes32 `json:"keymr"`
+	}
+	return struct {
+		DBlock dblock       `json:"dblock"`
+		Data   factom.Bytes `json:"rawdata"`
+	}{dblock{s.dbKeyMRs[*p.Height]}, s.dblocks[*p.Height]}
+}
+
+func (s *Server) chainHead(_ context.Context, params json.RawMessage) interface{} {
+	var p struct {
+		ChainID *factom.Bytes32 `json:"chainid"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.ChainID == nil {
+		return jsonrpc2.ErrorInvalidParams(nil)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res struct {
+		KeyMR              string `json:"chainhead"`
+		ChainInProcessList bool   `json:"chaininprocesslist"`
+	}
+	for _, reveal := range s.pending {
+		if bytes.Equal(reveal[1:1+len(p.ChainID)], p.ChainID[:]) {
+			res.ChainInProcessList = true
+			break
+		}
+	}
+	// Like factomd, a new chain that is pending has no chain head.
+	ch, ok := s.chains[*p.ChainID]
+	if !ok && !res.ChainInProcessList {
+		return ErrorMissingChainHead
+	}
+	if ok {
+		res.KeyMR = ch.Head.String()
+	}
+	return res
+}
+
+// ErrorInvalidCommit is returned for commits that are not well formed, and
+// reveals without a commit.
+var ErrorInvalidCommit = jsonrpc2.NewError(3, "Invalid Commit", nil)
+
+// ErrorRepeatedCommit matches factomd's error for an Entry Hash which has
+// already been committed.
+var ErrorRepeatedCommit = jsonrpc2.NewError(4, "Repeated Commit", nil)
+
+// Sizes of entry and chain commits.
+const (
+	commitLen      = 1 + 6 + 32 + 1 + 32 + 64
+	chainCommitLen = commitLen + 32 + 32
+)
+
+func (s *Server) commit(_ context.Context, params json.RawMessage) interface{} {
+	var p struct {
+		Commit factom.Bytes `json:"message"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return jsonrpc2.ErrorInvalidParams(nil)
+	}
+	var hash factom.Bytes32
+	switch len(p.Commit) {
+	case commitLen:
+		copy(hash[:], p.Commit[1+6:])
+	case chainCommitLen:
+		copy(hash[:], p.Commit[1+6+32+32:])
+	default:
+		return ErrorInvalidCommit
+	}
+	txID := factom.Bytes32(sha256.Sum256(p.Commit[:len(p.Commit)-96]))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range s.commits {
+		if h == hash && !s.revealed[hash] {
+			return ErrorRepeatedCommit
+		}
+	}
+	s.commits[txID] = hash
+	return struct {
+		Message string         `json:"message"`
+		TxID    factom.Bytes32 `json:"txid"`
+	}{"Entry Commit Success", txID}
+}
+
+func (s *Server) reveal(_ context.Context, params json.RawMessage) interface{} {
+	var p struct {
+		Entry factom.Bytes `json:"entry"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return jsonrpc2.ErrorInvalidParams(nil)
+	}
+	var e factom.Entry
+	if err := e.UnmarshalBinary(p.Entry); err != nil {
+		return jsonrpc2.ErrorInvalidParams(err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	committed := false
+	for _, hash := range s.commits {
+		if hash == *e.Hash {
+			committed = true
+			break
+		}
+	}
+	if !committed {
+		return ErrorInvalidCommit
+	}
+	if !s.revealed[*e.Hash] {
+		s.revealed[*e.Hash] = true
+		s.pending = append(s.pending, p.Entry)
+		// Like factomd, revealed Entries are available by hash
+		// before they are confirmed.
+		s.data[*e.Hash] = p.Entry
+	}
+	return struct {
+		Message   string         `json:"message"`
+		EntryHash factom.Bytes32 `json:"entryhash"`
+		ChainID   factom.Bytes32 `json:"chainid"`
+	}{"Entry Reveal Success", *e.Hash, *e.ChainID}
+}
+
+func (s *Server) pendingEntries(_ context.Context,
+	_ json.RawMessage) interface{} {
+	type pending struct {
+		EntryHash factom.Bytes32 `json:"entryhash"`
+		ChainID   factom.Bytes32 `json:"chainid"`
+		Status    string         `json:"status"`
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]pending, len(s.pending))
+	for i, reveal := range s.pending {
+		res[i].EntryHash = factom.ComputeEntryHash(reveal)
+		copy(res[i].ChainID[:], reveal[1:])
+		res[i].Status = "TransactionACK"
+	}
+	return res
+}
+
+// MerkleNode is a node of a receipt's Merkle branch.
+type MerkleNode struct {
+	Left  factom.Bytes32 `json:"left"`
+	Right factom.Bytes32 `json:"right"`
+	Top   factom.Bytes32 `json:"top"`
+}
+
+func (s *Server) receipt(_ context.Context, params json.RawMessage) interface{} {
+	var p struct {
+		Hash *factom.Bytes32 `json:"hash"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.Hash == nil {
+		return jsonrpc2.ErrorInvalidParams(nil)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ebKeyMR, ok := s.entryEBlocks[*p.Hash]
+	if !ok {
+		return ErrorNotFound
+	}
+
+	// The EBlock body leaves are its objects, which are not hashed.
+	eb := s.data[ebKeyMR]
+	var objects []factom.Bytes32
+	for i := factom.EBlockHeaderLen; i < len(eb); i += factom.EBlockObjectLen {
+		var obj factom.Bytes32
+		copy(obj[:], eb[i:])
+		objects = append(objects, obj)
+	}
+	var i int
+	for objects[i] != *p.Hash {
+		i++
+	}
+	branch := merkleBranch(objects, i)
+	branch = append(branch, merkleNode(
+		factom.ComputeEBlockHeaderHash(eb), branch[len(branch)-1].Top))
+
+	// The DBlock body leaves are the hashes of each ChainID|KeyMR.
+	var chainID factom.Bytes32
+	copy(chainID[:], eb)
+	height := binary.BigEndian.Uint32(eb[32*4+4:])
+	db := s.dblocks[height]
+	var leaves []factom.Bytes32
+	for i := factom.DBlockHeaderLen; i < len(db); i += factom.DBlockEBlockLen {
+		leaves = append(leaves, sha256.Sum256(db[i:i+factom.DBlockEBlockLen]))
+		if bytes.Equal(db[i:i+32], chainID[:]) {
+			branch = append(branch, merkleNode(chainID, ebKeyMR))
+		}
+	}
+	i = 0
+	for leaves[i] != branch[len(branch)-1].Top {
+		i++
+	}
+	branch = append(branch, merkleBranch(leaves, i)...)
+	branch = append(branch, merkleNode(factom.ComputeDBlockHeaderHash(db),
+		branch[len(branch)-1].Top))
+
+	type entry struct {
+		Hash factom.Bytes32 `json:"entryhash"`
+	}
+	type receipt struct {
+		Entry        entry          `json:"entry"`
+		MerkleBranch []MerkleNode   `json:"merklebranch"`
+		EBlockKeyMR  factom.Bytes32 `json:"entryblockkeymr"`
+		DBlockKeyMR  factom.Bytes32 `json:"directoryblockkeymr"`
+	}
+	return struct {
+		Receipt receipt `json:"receipt"`
+	}{receipt{entry{*p.Hash}, branch, ebKeyMR, s.dbKeyMRs[height]}}
+}
+
+// merkleBranch returns the nodes from the leaf at index i up to the root of
+// the Merkle tree of leaves, in which an odd node at any level is paired with
+// itself.
+func merkleBranch(leaves []factom.Bytes32, i int) []MerkleNode {
+	level := append([]factom.Bytes32(nil), leaves...)
+	var branch []MerkleNode
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		next := make([]factom.Bytes32, len(level)/2)
+		for j := range next {
+			next[j] = merkleNode(level[2*j], level[2*j+1]).Top
+		}
+		branch = append(branch, merkleNode(level[i&^1], level[i|1]))
+		level = next
+		i /= 2
+	}
+	return branch
+}
+
+func merkleNode(left, right factom.Bytes32) MerkleNode {
+	data := append(left[:len(left):len(left)], right[:]...)
+	return MerkleNode{Left: left, Right: right, Top: sha256.Sum256(data)}
+}
+
+func (s *Server) ack(_ context.Context, params json.RawMessage) interface{} {
+	var p struct {
+		Hash    *factom.Bytes32 `json:"hash"`
+		ChainID string          `json:"chainid"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.Hash == nil {
+		return jsonrpc2.ErrorInvalidParams(nil)
+	}
+
+	type status struct {
+		Status string `json:"status"`
+	}
+	var res struct {
+		Commit status `json:"commitdata"`
+		Reveal status `json:"entrydata"`
+	}
+	res.Commit.Status, res.Reveal.Status = "Unknown", "Unknown"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.ChainID == "c" {
+		if hash, ok := s.commits[*p.Hash]; ok {
+			res.Commit.Status = "TransactionACK"
+			if s.confirmed[hash] {
+				res.Commit.Status = "DBlockConfirmed"
+			}
+		}
+		return res
+	}
+	// Like factomd, an Entry is only acknowledged on its own Chain.
+	if reveal, ok := s.data[*p.Hash]; ok &&
+		factom.Bytes(reveal[1:1+32]).String() != p.ChainID {
+		return res
+	}
+	switch {
+	case s.confirmed[*p.Hash]:
+		res.Reveal.Status = "DBlockConfirmed"
+	case s.revealed[*p.Hash]:
+		res.Reveal.Status = "TransactionACK"
+	}
+	return res
+}
+
+// ExpireCommits removes all commits whose Entries have not been revealed, as
+// factomd does once a commit expires.
+func (s *Server) ExpireCommits() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for txID, hash := range s.commits {
+		if !s.revealed[hash] {
+			delete(s.commits, txID)
+		}
+	}
+}
+
+// Confirm adds all revealed Entries that are not yet confirmed to new
+// EBlocks, one per chain, in the order in which the chains were first
+// revealed.
+func (s *Server) Confirm() {
+	s.mu.Lock()
+	var chainIDs []factom.Bytes32
+	byChain := make(map[factom.Bytes32][]factom.Bytes)
+	for _, reveal := range s.pending {
+		var chainID factom.Bytes32
+		copy(chainID[:], reveal[1:])
+		if _, ok := byChain[chainID]; !ok {
+			chainIDs = append(chainIDs, chainID)
+		}
+		byChain[chainID] = append(byChain[chainID], reveal)
+	}
+	s.pending = nil
+	s.mu.Unlock()
+
+	for _, chainID := range chainIDs {
+		s.AddEBlock(byChain[chainID]...)
+	}
+}
@@ -5,15 +5,16 @@ import (
 	"compress/gzip"
 	"compress/zlib"
 	"context"
-	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"runtime"
 	"strings"
+	"time"
 
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/AdamSLevy/retry"
 	"github.com/Factom-Asset-Tokens/factom"
-	"golang.org/x/sync/errgroup"
 )
 
 // Metadata describes the Data from a Data Store Chain.
@@ -36,20 +37,46 @@ type Metadata struct {
 	*Compression `json:"compression,omitempty"`
 
 	// The Entry Hash of the first DBI Entry that describing the Data.
-	DBIStart *factom.Bytes32 `json:"dbi-start"`
+	// Required, unless Proof is true.
+	DBIStart *factom.Bytes32 `json:"dbi-start,omitempty"`
+
+	// Proof declares a proof of existence record, which has no DBI Start,
+	// because the Data is not stored on chain. See IsProof.
+	Proof bool `json:"proof,omitempty"`
 
 	// Optional additional JSON containing application defined Metadata.
 	AppMetadata json.RawMessage `json:"metadata,omitempty"`
+
+	// The value returned by the AppMetadataDecoder used by
+	// ParseOptions.ParseEntry, if any.
+	DecodedAppMetadata interface{} `json:"-"`
+
+	// The Directory Block Height, EBlock KeyMR, and Timestamp of the First
+	// Entry, which are populated by Lookup.
+	Height      uint32          `json:"-"`
+	EBlockKeyMR *factom.Bytes32 `json:"-"`
+	Timestamp   time.Time       `json:"-"`
+
+	// Pending is true if the First Entry has been revealed, but is not yet
+	// confirmed in a Directory Block, in which case Height, EBlockKeyMR,
+	// and Timestamp are not populated. See ParseOptions.AllowPending.
+	Pending bool `json:"-"`
 }
 
 // Compression describes compression settings for how the Data is stored.
 type Compression struct {
-	// Compression format used on the data. May be "gzip" or "zlib".
+	// Compression format used on the data. May be "gzip", "zlib", or
+	// FormatGzipSeekable.
 	Format string `json:"format"`
 
 	// The size of the compressed data. This is what is actually stored on
 	// the Data Store Chain.
 	Size uint64 `json:"size"`
+
+	// The uncompressed size of each frame and the compressed size of
+	// every frame, for FormatGzipSeekable only.
+	FrameSize uint64   `json:"frame-size,omitempty"`
+	Frames    []uint64 `json:"frames,omitempty"`
 }
 
 // Current Protocol and Version.
@@ -67,29 +94,196 @@ func NameIDs(dataHash *factom.Bytes32, namespace ...factom.Bytes) []factom.Bytes
 }
 
 // Lookup the Metadata for a given Data Store chainID.
+//
+// This is equivalent to ParseOptions{}.Lookup(ctx, c, chainID).
 func Lookup(ctx context.Context, c *factom.Client,
 	chainID *factom.Bytes32) (Metadata, error) {
+	return ParseOptions{}.Lookup(ctx, c, chainID)
+}
+
+// ParseEntry attempts to parse e as the First Entry from a Data Store Chain.
+//
+// This is equivalent to ParseOptions{}.ParseEntry(e).
+func ParseEntry(e factom.Entry) (Metadata, error) {
+	return ParseOptions{}.ParseEntry(e)
+}
+
+// AppMetadataDecoder validates and optionally decodes the application defined
+// Metadata of a Data Store. The appMetadata is nil if the Data Store does not
+// declare any.
+//
+// If an error is returned, the Data Store is rejected. Otherwise the returned
+// value, which may be nil, is saved in Metadata.DecodedAppMetadata.
+type AppMetadataDecoder func(appMetadata json.RawMessage) (interface{}, error)
+
+// ParseOptions control how the First Entry of a Data Store Chain is parsed and
+// validated. The zero value applies only the validation required by the
+// protocol.
+type ParseOptions struct {
+	// Strict rejects Metadata JSON with unknown fields or duplicate keys.
+	Strict bool
+
+	// AppMetadata, if not nil, is used for all Data Stores whose
+	// namespace does not appear in Namespaces.
+	AppMetadata AppMetadataDecoder
+
+	// Namespaces maps a NamespaceKey to the AppMetadataDecoder used for
+	// Data Stores within that exact namespace.
+	Namespaces map[string]AppMetadataDecoder
+
+	// Wait, if not nil, is the retry.Policy used by Lookup to wait for a
+	// Data Store Chain that is pending to be confirmed in a Directory
+	// Block, such as DefaultPublishPolicy. Since factomd does not
+	// distinguish a Chain that has not yet been revealed from one that
+	// does not exist, Lookup also waits for Chains that are not found.
+	Wait retry.Policy
+
+	// AllowPending allows Lookup to return the Metadata of a Data Store
+	// whose First Entry has been revealed, but is not yet confirmed in a
+	// Directory Block, by finding it in factomd's pending Entries. Such
+	// Metadata has Pending set, and may be downloaded as usual, since
+	// factomd serves revealed Entries by hash before they are confirmed.
+	AllowPending bool
+}
+
+// NamespaceKey returns a string that uniquely identifies the given namespace
+// for use as a key in ParseOptions.Namespaces.
+func NamespaceKey(namespace ...factom.Bytes) string {
+	var key strings.Builder
+	for _, id := range namespace {
+		fmt.Fprintf(&key, "%v:%s,", len(id), id)
+	}
+	return key.String()
+}
+
+// Lookup the Metadata for a given Data Store chainID, and parse it according
+// to opts.
+func (opts ParseOptions) Lookup(ctx context.Context, c *factom.Client,
+	chainID *factom.Bytes32) (Metadata, error) {
 
 	// Get the first Entry in the Chain...
 
+	if opts.Wait != nil {
+		if err := waitConfirmed(ctx, c, opts.Wait, chainID); err != nil {
+			return Metadata{}, err
+		}
+	}
+
 	// Get the first EBlock in the Chain.
 	firstEB := factom.EBlock{ChainID: chainID}
+	if opts.AllowPending {
+		_, err := firstEB.GetChainHead(ctx, c)
+		var jErr jsonrpc2.Error
+		if firstEB.KeyMR == nil && (err == nil || errors.As(err, &jErr)) {
+			// The Chain has no confirmed EBlock.
+			m, pErr := opts.lookupPending(ctx, c, chainID)
+			if pErr != nil && err != nil && errors.Is(pErr, errNotFound) {
+				return Metadata{}, err
+			}
+			return m, pErr
+		}
+		if err != nil {
+			return Metadata{}, err
+		}
+	}
 	if err := firstEB.GetFirst(ctx, c); err != nil {
 		return Metadata{}, err
 	}
 
+	// The Entry Timestamps are established by the DBlock.
+	db := factom.DBlock{Height: firstEB.Height}
+	if err := db.Get(ctx, c); err != nil {
+		return Metadata{}, err
+	}
+	if eb := db.EBlock(*chainID); eb == nil || *eb.KeyMR != *firstEB.KeyMR {
+		return Metadata{}, fmt.Errorf("first EBlock not found in DBlock")
+	}
+	firstEB.SetTimestamp(db.Timestamp)
+
 	// Get the First Entry in the EBlock.
 	firstE := firstEB.Entries[0]
 	if err := firstE.Get(ctx, c); err != nil {
 		return Metadata{}, err
 	}
 
+	// Ensure that factomd returned the requested chain.
+	if factom.ComputeChainID(firstE.ExtIDs) != *chainID {
+		return Metadata{}, fmt.Errorf("invalid First Entry ChainID")
+	}
+
 	// Parse the First Entry and return the Metadata or any error.
-	return ParseEntry(firstE)
+	m, err := opts.ParseEntry(firstE)
+	if err != nil {
+		return Metadata{}, err
+	}
+	m.Height = firstEB.Height
+	m.EBlockKeyMR = firstEB.KeyMR
+	m.Timestamp = firstE.Timestamp
+	return m, nil
 }
 
-// ParseEntry attempts to parse e as the First Entry from a Data Store Chain.
-func ParseEntry(e factom.Entry) (Metadata, error) {
+// errNotFound is returned by lookupPending if there is no pending First Entry
+// for the Chain.
+var errNotFound = errors.New("Data Store Chain not found")
+
+// lookupPending returns the Metadata parsed from the First Entry of chainID
+// found in factomd's pending Entries.
+func (opts ParseOptions) lookupPending(ctx context.Context, c *factom.Client,
+	chainID *factom.Bytes32) (Metadata, error) {
+	var pe factom.PendingEntries
+	if err := pe.Get(ctx, c); err != nil {
+		return Metadata{}, err
+	}
+	// Pending Entries are in the order they were processed, so the first
+	// one with the ExtIDs of the Chain is the First Entry.
+	for _, e := range pe.Entries(chainID) {
+		firstE := factom.Entry{Hash: e.Hash}
+		if err := firstE.Get(ctx, c); err != nil {
+			return Metadata{}, err
+		}
+		if *firstE.ChainID != *chainID ||
+			factom.ComputeChainID(firstE.ExtIDs) != *chainID {
+			continue
+		}
+		m, err := opts.ParseEntry(firstE)
+		if err != nil {
+			return Metadata{}, err
+		}
+		m.Pending = true
+		return m, nil
+	}
+	return Metadata{}, errNotFound
+}
+
+// errNotConfirmed is returned while waiting for a Chain that does not yet
+// have a confirmed EBlock.
+var errNotConfirmed = errors.New("Data Store Chain is not yet confirmed")
+
+// waitConfirmed waits, according to policy, until factomd has a confirmed
+// EBlock for chainID.
+func waitConfirmed(ctx context.Context, c *factom.Client, policy retry.Policy,
+	chainID *factom.Bytes32) error {
+	return retry.Run(ctx, policy, func(err error) error {
+		if err != nil && !errors.Is(err, errNotConfirmed) &&
+			!Retryable(err) {
+			return retry.ErrorStop(err)
+		}
+		return err
+	}, nil, func() error {
+		eb := factom.EBlock{ChainID: chainID}
+		if _, err := eb.GetChainHead(ctx, c); err != nil {
+			return err
+		}
+		if eb.KeyMR == nil {
+			return errNotConfirmed
+		}
+		return nil
+	})
+}
+
+// ParseEntry attempts to parse e as the First Entry from a Data Store Chain,
+// according to opts.
+func (opts ParseOptions) ParseEntry(e factom.Entry) (Metadata, error) {
 
 	// Validate and parse ExtIDs
 
@@ -112,7 +306,20 @@ func ParseEntry(e factom.Entry) (Metadata, error) {
 
 	// Parse the JSON.
 	md := Metadata{DataHash: &dataHash, Entry: e}
-	if err := json.Unmarshal(e.Content, &md); err != nil {
+	if opts.Strict {
+		if err := checkDuplicateKeys(e.Content); err != nil {
+			return Metadata{}, fmt.Errorf("Content: %w", err)
+		}
+		d := json.NewDecoder(bytes.NewReader(e.Content))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&md); err != nil {
+			return Metadata{}, fmt.Errorf("Content: %w", err)
+		}
+		if d.More() {
+			return Metadata{}, fmt.Errorf(
+				"Content: invalid data after top-level value")
+		}
+	} else if err := json.Unmarshal(e.Content, &md); err != nil {
 		return Metadata{}, err
 	}
 
@@ -126,17 +333,27 @@ func ParseEntry(e factom.Entry) (Metadata, error) {
 		return Metadata{}, fmt.Errorf(`Content: invalid "size"`)
 	}
 
-	// We must have a DBI Start Hash.
-	if md.DBIStart == nil {
+	// We must have a DBI Start Hash, unless this is explicitly a proof of
+	// existence record, which has no on chain data.
+	if md.Proof {
+		if md.DBIStart != nil {
+			return Metadata{}, fmt.Errorf(
+				`Content: "proof" with "dbi-start"`)
+		}
+		if md.Compression != nil {
+			return Metadata{}, fmt.Errorf(
+				`Content: "proof" with "compression"`)
+		}
+	} else if md.DBIStart == nil {
 		return Metadata{}, fmt.Errorf(`Content: missing "dbi-start"`)
 	}
 
 	// Validate optional compression settings.
 	if md.Compression != nil {
 
-		// Only support "zlib" and "gzip".
+		// Only support "zlib", "gzip", and "gzip-seekable".
 		switch strings.ToLower(md.Format) {
-		case "zlib", "gzip":
+		case "zlib", "gzip", FormatGzipSeekable:
 		default:
 			return Metadata{}, fmt.Errorf(
 				`Content: unsupported "compression"."format"`)
@@ -147,193 +364,153 @@ func ParseEntry(e factom.Entry) (Metadata, error) {
 			return Metadata{}, fmt.Errorf(
 				`Content: invalid "compression"."size"`)
 		}
-	}
-
-	return md, nil
-}
 
-const (
-	MaxDBIEHashCount       = factom.EntryMaxDataLen / 32
-	MaxLinkedDBIEHashCount = (factom.EntryMaxDataLen - 32 - 2) / 32
-)
-
-// Download all Data Block Index and Data Block Entries required to reconstruct
-// the on chain data, and then decompresses the data if necessary before
-// writing it to the given data io.Writer.
-//
-// The Data Block Entries are downloaded concurrently as they are loaded from
-// the DBI.
-//
-// The sha256d hash of the data written to data, is verified.
-func (m Metadata) Download(ctx context.Context, c *factom.Client, data io.Writer) error {
-
-	// Get the on-chain size.
-	size := m.Size
-	if m.Compression != nil {
-		size = m.Compression.Size
+		if err := md.Compression.validateFrames(md.Size); err != nil {
+			return Metadata{}, fmt.Errorf(
+				`Content: "compression": %w`, err)
+		}
 	}
 
-	// Compute the expected DB Count.
-	totalDBCount := int(size / factom.EntryMaxDataLen)
-	if size%factom.EntryMaxDataLen > 0 {
-		totalDBCount++
+	// Validate the application defined Metadata.
+	decode, ok := opts.Namespaces[NamespaceKey(md.Namespace()...)]
+	if !ok {
+		decode = opts.AppMetadata
+	}
+	if decode != nil {
+		v, err := decode(md.AppMetadata)
+		if err != nil {
+			return Metadata{}, fmt.Errorf(`Content: "metadata": %w`, err)
+		}
+		md.DecodedAppMetadata = v
 	}
 
-	// cData will contain the on chain data. We preallocate this so that we
-	// can have the Data Block Entries populate it directly as they are
-	// downloaded concurrently.
-	cData := make([]byte, size)
-
-	// Pass along the Data Block Entries from the DBI to this channel.
-	dbEs := make(chan factom.Entry, totalDBCount)
+	return md, nil
+}
 
-	// Download and process the Data Block Entries concurrently as they are
-	// parsed from the DBI, which is downloaded below.
-	if ctx == nil {
-		ctx = context.Background()
+// Namespace returns the application defined Namespace of the Data Store,
+// which are the NameIDs following the data hash.
+func (m Metadata) Namespace() []factom.Bytes {
+	if len(m.Entry.ExtIDs) < 2 {
+		return nil
 	}
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	g, ctx := errgroup.WithContext(ctx)
-	for i := 0; i < runtime.NumCPU(); i++ {
-		g.Go(func() error {
-			for dbE := range dbEs {
-				origCap := cap(dbE.Content)
-				if err := dbE.Get(ctx, c); err != nil {
+	return m.Entry.ExtIDs[2:]
+}
+
+// checkDuplicateKeys returns an error if any JSON object within data declares
+// the same key more than once.
+func checkDuplicateKeys(data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var check func() error
+	check = func() error {
+		tkn, err := d.Token()
+		if err != nil {
+			return err
+		}
+		delim, ok := tkn.(json.Delim)
+		if !ok {
+			return nil
+		}
+		switch delim {
+		case '{':
+			keys := make(map[string]struct{})
+			for d.More() {
+				tkn, err := d.Token()
+				if err != nil {
 					return err
 				}
-				// Ensure that the Content did not exceed the
-				// original capacity of the underlying cData
-				// slice, and that the Content is filled to
-				// capacity of either the underlying cData
-				// slice, or the Entry limit.
-				if cap(dbE.Content) != origCap ||
-					(len(dbE.Content) < factom.EntryMaxDataLen &&
-						len(dbE.Content) != cap(dbE.Content)) {
-					return fmt.Errorf(
-						"invalid Data Block Entry Content")
+				key := tkn.(string)
+				if _, ok := keys[key]; ok {
+					return fmt.Errorf("duplicate key: %q", key)
 				}
-			}
-			return nil
-		})
-	}
-
-	// Download the DBI linked list and populate the Data Block Entry Hashes.
-
-	// dbiBuf will hold the Content of the current DBI Entry.
-	dbiBuf := bytes.NewBuffer(nil)
-
-	// dbiEHash holds the Entry Hash for the next DBI Entry in the Linked
-	// List.
-	dbiEHash := *m.DBIStart
-
-	for i := 0; i < totalDBCount; i++ {
-		// If we have no Data Block Hashes to parse, download and
-		// validate the next DBI Entry.
-		if dbiBuf.Len() == 0 {
-			// Download the next DBI Entry.
-			dbiE := factom.Entry{Hash: &dbiEHash}
-			if err := dbiE.Get(ctx, c); err != nil {
-				return err
-			}
-
-			// Ensure there are no incomplete hashes.
-			if len(dbiE.Content)%32 > 0 {
-				return fmt.Errorf("invalid DBI Entry Content")
-			}
-
-			// dbCount is the number of Data Block Hashes in this
-			// DBI Entry.
-			dbCount := len(dbiE.Content) / 32
-
-			// remaining is the number of Data Block Hashes that
-			// still need to be parsed or downloaded.
-			remaining := totalDBCount - i
-
-			// If there are more remaining than can fit in a single
-			// DBI Entry...
-			if remaining > MaxDBIEHashCount {
-
-				// Require exact number of Hashes
-				if dbCount != MaxLinkedDBIEHashCount {
-					return fmt.Errorf("invalid DBI Entry Content")
+				keys[key] = struct{}{}
+				if err := check(); err != nil {
+					return err
 				}
-
-				// Require a DBI Entry Link.
-				if len(dbiE.ExtIDs) != 1 ||
-					len(dbiE.ExtIDs[0]) != 32 {
-					return fmt.Errorf(
-						"missing or invalid DBI Entry link")
+			}
+		case '[':
+			for d.More() {
+				if err := check(); err != nil {
+					return err
 				}
-
-				// Parse the next DBI Entry Hash.
-				copy(dbiEHash[:], dbiE.ExtIDs[0])
-			} else if dbCount != remaining {
-				// Otherwise this DBI Entry must include all
-				// remaining DB Hashes.
-				fmt.Println("dbi E", i, dbCount, remaining)
-				return fmt.Errorf("invalid DBI Entry Content")
 			}
-
-			// Set up the new dbiBuf to parse the DB Hashes from.
-			dbiBuf = bytes.NewBuffer(dbiE.Content)
 		}
+		// Consume the closing delimiter.
+		_, err = d.Token()
+		return err
+	}
+	return check()
+}
 
-		// Parse out the next Data Block Entry Hash.
-		dbE := factom.Entry{Hash: new(factom.Bytes32)}
-		dbiBuf.Read(dbE.Hash[:])
-
-		// Set the Content of each Data Block so the Content will get
-		// unmarshalled directly into the proper location within cData
-		// when the Data Blocks are downloaded concurrently.
-		cDataI := i * factom.EntryMaxDataLen
-		dbE.Content = cData[cDataI:cDataI]
+const (
+	MaxDBIEHashCount       = factom.EntryMaxDataLen / 32
+	MaxLinkedDBIEHashCount = (factom.EntryMaxDataLen - 32 - 2) / 32
+)
 
-		dbEs <- dbE
+// EntryCounts returns the number of DBI Entries and Data Block Entries
+// required to store size bytes of data on chain.
+//
+// There is always at least one DBI Entry, even for a single Data Block, since
+// the First Entry must reference the DBI.
+func EntryCounts(size uint64) (dbiECount, dbECount int) {
+	// Compute the expected Data Block Entry Count.
+	dbECount = int(size / factom.EntryMaxDataLen)
+	if size%factom.EntryMaxDataLen > 0 {
+		dbECount++
 	}
-	close(dbEs)
 
-	// Wait until all Data Block Entries are processed.
-	if err := g.Wait(); err != nil {
-		return err
+	// Compute the expected Data Block Index Entry Count. A single DBI
+	// Entry holds up to MaxDBIEHashCount hashes. Only larger DBIs are
+	// split into a linked list, otherwise the count below would be zero
+	// for 1 or 2 Data Blocks.
+	if dbECount <= MaxDBIEHashCount {
+		return 1, dbECount
 	}
+	dbiECount = dbECount / MaxLinkedDBIEHashCount
+	if dbECount%MaxLinkedDBIEHashCount > (MaxDBIEHashCount - MaxLinkedDBIEHashCount) {
+		dbiECount++
+	}
+	return
+}
 
-	dataBuf := io.Reader(bytes.NewBuffer(cData))
-
-	// Decompress the data, if necessary
+// EntryCounts returns the number of DBI Entries and Data Block Entries that
+// make up the Data Store described by m, not including the First Entry.
+func (m Metadata) EntryCounts() (dbiECount, dbECount int) {
+	size := m.Size
 	if m.Compression != nil {
-		switch strings.ToLower(m.Format) {
-		case "gzip":
-			r, err := gzip.NewReader(dataBuf)
-			if err != nil {
-				return err
-			}
-			defer r.Close()
-			dataBuf = r
-		case "zlib":
-			r, err := zlib.NewReader(dataBuf)
-			if err != nil {
-				return err
-			}
-			defer r.Close()
-			dataBuf = r
-		default:
-			panic(`invalid "compression"."format"`)
-		}
+		size = m.Compression.Size
 	}
+	return EntryCounts(size)
+}
 
-	// Compute the data hash and write to data.
-	hash := sha256.New()
-	data = io.MultiWriter(hash, data)
+// GetDBI downloads the Data Block Index and returns all Data Block Entry
+// Hashes in order.
+//
+// This is equivalent to DownloadOptions{}.GetDBI(ctx, c, m).
+func (m Metadata) GetDBI(ctx context.Context, c *factom.Client) (
+	[]factom.Bytes32, error) {
+	return DownloadOptions{}.GetDBI(ctx, c, m)
+}
 
-	if _, err := io.Copy(data, dataBuf); err != nil {
-		return err
-	}
+// Download all Data Block Index and Data Block Entries required to reconstruct
+// the on chain data, and then decompresses the data if necessary before
+// writing it to the given data io.Writer.
+//
+// This is equivalent to DownloadOptions{}.Download(ctx, c, m, data).
+func (m Metadata) Download(ctx context.Context, c *factom.Client, data io.Writer) error {
+	return DownloadOptions{}.Download(ctx, c, m, data)
+}
 
-	// Verify data hash
-	if *m.DataHash != sha256.Sum256(hash.Sum(nil)) {
-		return fmt.Errorf("invalid data hash")
+// newReader returns an io.ReadCloser that decompresses the data read from r
+// according to the Compression Format.
+func (cmp Compression) newReader(r io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(cmp.Format) {
+	case "gzip", FormatGzipSeekable:
+		// The frames of FormatGzipSeekable are concatenated gzip
+		// members, which gzip.Reader reads as a single stream.
+		return gzip.NewReader(r)
+	case "zlib":
+		return zlib.NewReader(r)
 	}
-
-	return nil
+	return nil, fmt.Errorf(`unsupported "compression"."format"`)
 }
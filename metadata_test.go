@@ -3,11 +3,17 @@ package datastore
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/AdamSLevy/retry"
 	"github.com/Factom-Asset-Tokens/factom"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
 )
 
 func TestMetadata(t *testing.T) {
@@ -35,3 +41,185 @@ func TestMetadata(t *testing.T) {
 
 	assert.EqualValues(dataHash, hash)
 }
+
+func newFirstEntry(content string, namespace ...factom.Bytes) factom.Entry {
+	dataHash := factom.Bytes32{1}
+	nameIDs := NameIDs(&dataHash, namespace...)
+	chainID := factom.ComputeChainID(nameIDs)
+	return factom.Entry{
+		ChainID: &chainID,
+		ExtIDs:  nameIDs,
+		Content: factom.Bytes(content),
+	}
+}
+
+const dbiStart = `"dbi-start":"0000000000000000000000000000000000000000000000000000000000000001"`
+
+func TestParseEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	e := newFirstEntry(`{"data-store":"1.0","size":10,` + dbiStart +
+		`,"metadata":{"filename":"a"},"extra":1}`)
+	_, err := ParseEntry(e)
+	assert.NoError(err)
+	_, err = ParseOptions{Strict: true}.ParseEntry(e)
+	assert.EqualError(err, `Content: json: unknown field "extra"`)
+
+	e = newFirstEntry(`{"data-store":"1.0","size":10,"size":11,` +
+		dbiStart + `}`)
+	_, err = ParseEntry(e)
+	assert.NoError(err)
+	_, err = ParseOptions{Strict: true}.ParseEntry(e)
+	assert.EqualError(err, `Content: duplicate key: "size"`)
+
+	e = newFirstEntry(`{"data-store":"1.0","size":10,` + dbiStart +
+		`,"compression":{"format":"gzip","size":5,"level":9}}`)
+	_, err = ParseOptions{Strict: true}.ParseEntry(e)
+	assert.EqualError(err, `Content: json: unknown field "level"`)
+
+	schema, err := JSONSchema([]byte(`{"type": "object",
+		"properties": {"filename": {"type": "string"}},
+		"required": ["filename"]}`))
+	require.NoError(t, err)
+	opts := ParseOptions{
+		Strict: true,
+		Namespaces: map[string]AppMetadataDecoder{
+			NamespaceKey(factom.Bytes("app")): schema,
+		},
+	}
+
+	// Data Stores outside of the namespace are not validated.
+	e = newFirstEntry(`{"data-store":"1.0","size":10,` + dbiStart + `}`)
+	m, err := opts.ParseEntry(e)
+	assert.NoError(err)
+	assert.Nil(m.DecodedAppMetadata)
+
+	e = newFirstEntry(`{"data-store":"1.0","size":10,`+dbiStart+`}`,
+		factom.Bytes("app"))
+	_, err = opts.ParseEntry(e)
+	assert.EqualError(err, `Content: "metadata": /: expected type [object], got null`)
+
+	e = newFirstEntry(`{"data-store":"1.0","size":10,`+dbiStart+
+		`,"metadata":{"filename":"a"}}`, factom.Bytes("app"))
+	m, err = opts.ParseEntry(e)
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{"filename": "a"},
+		m.DecodedAppMetadata)
+	assert.Equal([]factom.Bytes{factom.Bytes("app")}, m.Namespace())
+
+	// A global decoder applies to all other namespaces.
+	opts.AppMetadata = func(json.RawMessage) (interface{}, error) {
+		return nil, fmt.Errorf("rejected")
+	}
+	e = newFirstEntry(`{"data-store":"1.0","size":10,` + dbiStart + `}`)
+	_, err = opts.ParseEntry(e)
+	assert.EqualError(err, `Content: "metadata": rejected`)
+}
+
+func TestLookupWait(t *testing.T) {
+	require := require.New(t)
+
+	_, _, reveals := generateTestStore(t, 3*factom.EntryMaxDataLen, "")
+	s := factomdtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+
+	// Another chain so that the Data Store is not at height 0.
+	_, _, other := generateTestStore(t, 100, "")
+	s.AddEBlock(other[0])
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	g, err := Replay(es, reveals)
+	require.NoError(err)
+	require.NoError(g.Publish(nil, c))
+
+	_, err = Lookup(nil, c, &g.ChainID)
+	assert.Error(t, err, "pending")
+
+	opts := ParseOptions{Wait: retry.LimitTotal{Limit: 10 * time.Second,
+		Policy: retry.Constant(10 * time.Millisecond)}}
+	type result struct {
+		m   Metadata
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		m, err := opts.Lookup(nil, c, &g.ChainID)
+		done <- result{m, err}
+	}()
+	for s.Calls("chain-head") < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	s.Confirm()
+	res := <-done
+	require.NoError(res.err)
+	m := res.m
+
+	eb := factom.EBlock{ChainID: &g.ChainID}
+	require.NoError(eb.GetFirst(nil, c))
+	assert.Equal(t, eb.KeyMR, m.EBlockKeyMR)
+	assert.Equal(t, uint32(1), m.Height)
+	assert.Equal(t, eb.Height, m.Height)
+	// The single minute marker of each test EBlock is minute 1.
+	assert.Equal(t, factomdtest.Genesis.Add(11*time.Minute).Unix(),
+		m.Timestamp.Unix())
+
+	// Waiting for a Chain that never appears fails.
+	missing := factom.Bytes32{1}
+	opts.Wait = retry.LimitAttempts{Limit: 3,
+		Policy: retry.Constant(time.Millisecond)}
+	_, err = opts.Lookup(nil, c, &missing)
+	assert.Error(t, err)
+}
+
+func TestLookupPending(t *testing.T) {
+	require := require.New(t)
+
+	data, _, reveals := generateTestStore(t, 3*factom.EntryMaxDataLen, "zlib")
+	s := factomdtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	g, err := Replay(es, reveals)
+	require.NoError(err)
+
+	opts := ParseOptions{AllowPending: true}
+	missing := factom.Bytes32{1}
+	_, err = opts.Lookup(nil, c, &missing)
+	assert.Error(t, err)
+
+	// Another Entry with the same ExtIDs may follow the First Entry.
+	first := factom.Entry{ChainID: &g.ChainID}
+	require.NoError(first.UnmarshalBinary(reveals[0]))
+	first.Content = factom.Bytes("not a Data Store")
+	dup, err := first.MarshalBinary()
+	require.NoError(err)
+	dupHash := factom.ComputeEntryHash(dup)
+	dupCommit, dupTxID := factom.GenerateCommit(es, dup, &dupHash, false)
+
+	require.NoError(g.Publish(nil, c))
+	require.NoError(SubmitCommit(nil, c, dupCommit, &dupTxID))
+	require.NoError(SubmitReveal(nil, c, dup, &dupHash))
+
+	_, err = Lookup(nil, c, &g.ChainID)
+	assert.Error(t, err, "pending")
+
+	m, err := opts.Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+	assert.True(t, m.Pending)
+	assert.Nil(t, m.EBlockKeyMR)
+	assert.Equal(t, g.EntryHashes[0], *m.Entry.Hash)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(m.Download(nil, c, buf))
+	assert.Equal(t, data, buf.Bytes())
+
+	s.Confirm()
+	m, err = opts.Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+	assert.False(t, m.Pending)
+	assert.NotNil(t, m.EBlockKeyMR)
+}
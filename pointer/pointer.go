@@ -0,0 +1,257 @@
+// Package pointer implements mutable, named pointers to Data Stores.
+//
+// Data Stores are immutable, so a Pointer Chain is used to publish the latest
+// version of some named data. The Chain ID is derived from the name, the
+// owner's ed25519 public key, and a namespace, so it can be computed by
+// anyone who knows them, and no one else can claim the name for that owner.
+//
+// Each subsequent Entry in the chain is a Pointer to a Data Store Chain ID,
+// signed by the owner, which declares its version and references the Entry
+// Hash of the previous version. Entries that are not correctly signed, or that
+// do not extend the latest valid version, are ignored.
+//
+// First Entry
+//
+//	ExtIDs: ["data-store-pointer", <name>, <owner public key>, <namespace>...]
+//	Content: {"data-store-pointer":"1.0"}
+//
+// Pointer Entry
+//
+//	ExtIDs: [<ed25519 signature of Chain ID + Content>]
+//	Content: {"version":1,"chainid":"<data store chain id>","prev":"<entry hash>","metadata":{...}}
+package pointer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+
+	"github.com/Factom-Asset-Tokens/fds"
+)
+
+const (
+	Protocol = "data-store-pointer"
+	Version  = "1.0"
+)
+
+// Chain identifies a Pointer Chain.
+type Chain struct {
+	ChainID   factom.Bytes32
+	Name      string
+	Owner     ed25519.PublicKey
+	Namespace []factom.Bytes
+}
+
+// NewChain returns the Chain for the given name, owner and namespace.
+func NewChain(name string, owner ed25519.PublicKey,
+	namespace ...factom.Bytes) Chain {
+	ch := Chain{Name: name, Owner: owner, Namespace: namespace}
+	ch.ChainID = factom.ComputeChainID(ch.NameIDs())
+	return ch
+}
+
+// NameIDs returns the ExtIDs of the First Entry of ch.
+func (ch Chain) NameIDs() []factom.Bytes {
+	return append([]factom.Bytes{[]byte(Protocol), []byte(ch.Name),
+		factom.Bytes(ch.Owner)}, ch.Namespace...)
+}
+
+// FirstEntry returns the First Entry of ch. Its ChainID is nil so that
+// factom.Entry.Compose creates the chain.
+func (ch Chain) FirstEntry() factom.Entry {
+	return factom.Entry{
+		ExtIDs:  ch.NameIDs(),
+		Content: []byte(fmt.Sprintf(`{%q:%q}`, Protocol, Version)),
+	}
+}
+
+// Pointer is a version of a Pointer Chain.
+type Pointer struct {
+	// The Version, starting at 1 and incrementing with each update.
+	Version uint64 `json:"version"`
+
+	// The Chain ID of the Data Store pointed to.
+	DataStore *factom.Bytes32 `json:"chainid"`
+
+	// The Entry Hash of the previous version, nil for version 1.
+	Prev *factom.Bytes32 `json:"prev,omitempty"`
+
+	// Optional application defined metadata.
+	AppMetadata json.RawMessage `json:"metadata,omitempty"`
+
+	// The Entry Hash of this version.
+	EntryHash *factom.Bytes32 `json:"-"`
+}
+
+// Update returns a new Pointer Entry, signed by sk, that points to dataStore
+// and follows prev, which must be nil for the first version.
+func (ch Chain) Update(sk factom.SK1Key, prev *Pointer,
+	dataStore *factom.Bytes32, appMetadata json.RawMessage) (
+	factom.Entry, error) {
+
+	if !bytes.Equal(sk.PublicKey(), ch.Owner) {
+		return factom.Entry{}, fmt.Errorf("sk is not the owner")
+	}
+
+	p := Pointer{Version: 1, DataStore: dataStore, AppMetadata: appMetadata}
+	if prev != nil {
+		if prev.EntryHash == nil {
+			return factom.Entry{}, fmt.Errorf("prev.EntryHash is nil")
+		}
+		p.Version = prev.Version + 1
+		p.Prev = prev.EntryHash
+	}
+
+	content, err := json.Marshal(p)
+	if err != nil {
+		return factom.Entry{}, err
+	}
+
+	chainID := ch.ChainID
+	sig := ed25519.Sign(sk.PrivateKey(), append(chainID[:], content...))
+	return factom.Entry{
+		ChainID: &chainID,
+		ExtIDs:  []factom.Bytes{sig},
+		Content: content,
+	}, nil
+}
+
+// ParseEntry attempts to parse e as the First Entry of a Pointer Chain.
+func ParseEntry(e factom.Entry) (Chain, error) {
+	if len(e.ExtIDs) < 3 ||
+		string(e.ExtIDs[0]) != Protocol ||
+		len(e.ExtIDs[2]) != ed25519.PublicKeySize {
+		return Chain{}, fmt.Errorf("invalid ExtIDs")
+	}
+
+	var content map[string]string
+	if err := json.Unmarshal(e.Content, &content); err != nil {
+		return Chain{}, fmt.Errorf("json.Unmarshal(): %w", err)
+	}
+	if content[Protocol] != Version {
+		return Chain{}, fmt.Errorf("unsupported %q version", Protocol)
+	}
+
+	ch := NewChain(string(e.ExtIDs[1]), ed25519.PublicKey(e.ExtIDs[2]),
+		e.ExtIDs[3:]...)
+	if e.ChainID != nil && *e.ChainID != ch.ChainID {
+		return Chain{}, fmt.Errorf("invalid ChainID")
+	}
+	return ch, nil
+}
+
+// ParseEntry attempts to parse e as a Pointer Entry and verifies its
+// signature. It does not verify that p extends any previous version.
+func (ch Chain) ParseEntry(e factom.Entry) (Pointer, error) {
+	if e.ChainID == nil || *e.ChainID != ch.ChainID {
+		return Pointer{}, fmt.Errorf("invalid ChainID")
+	}
+	if len(e.ExtIDs) != 1 || len(e.ExtIDs[0]) != ed25519.SignatureSize {
+		return Pointer{}, fmt.Errorf("invalid ExtIDs")
+	}
+	chainID := ch.ChainID
+	if !ed25519.Verify(ch.Owner, append(chainID[:], e.Content...),
+		e.ExtIDs[0]) {
+		return Pointer{}, fmt.Errorf("invalid signature")
+	}
+
+	var p Pointer
+	d := json.NewDecoder(bytes.NewReader(e.Content))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&p); err != nil {
+		return Pointer{}, fmt.Errorf("json.Unmarshal(): %w", err)
+	}
+	if p.Version == 0 || p.DataStore == nil ||
+		(p.Version == 1) != (p.Prev == nil) {
+		return Pointer{}, fmt.Errorf("invalid pointer")
+	}
+	p.EntryHash = e.Hash
+	return p, nil
+}
+
+// Lookup the Chain for the given Pointer chainID.
+func Lookup(ctx context.Context, c *factom.Client,
+	chainID *factom.Bytes32) (Chain, error) {
+	eb := factom.EBlock{ChainID: chainID}
+	if err := eb.GetFirst(ctx, c); err != nil {
+		return Chain{}, err
+	}
+	first := eb.Entries[0]
+	if err := first.Get(ctx, c); err != nil {
+		return Chain{}, err
+	}
+	return ParseEntry(first)
+}
+
+// History returns all valid versions of ch, in order starting with version 1.
+//
+// A Pointer is valid if it is correctly signed and its version and Prev
+// immediately follow the latest valid Pointer before it in the chain. All
+// other Entries are ignored.
+func (ch Chain) History(ctx context.Context, c *factom.Client) (
+	[]Pointer, error) {
+	chainID := ch.ChainID
+	ebs, err := factom.EBlock{ChainID: &chainID}.GetPrevAll(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []Pointer
+	for i := len(ebs) - 1; i >= 0; i-- {
+		for _, e := range ebs[i].Entries {
+			if err := e.Get(ctx, c); err != nil {
+				return nil, err
+			}
+			p, err := ch.ParseEntry(e)
+			if err != nil {
+				continue
+			}
+			if len(history) == 0 {
+				if p.Version != 1 {
+					continue
+				}
+			} else if latest := history[len(history)-1]; p.Version !=
+				latest.Version+1 || *p.Prev != *latest.EntryHash {
+				continue
+			}
+			history = append(history, p)
+		}
+	}
+	return history, nil
+}
+
+// Resolve returns the latest valid version of ch.
+func (ch Chain) Resolve(ctx context.Context, c *factom.Client) (Pointer, error) {
+	history, err := ch.History(ctx, c)
+	if err != nil {
+		return Pointer{}, err
+	}
+	if len(history) == 0 {
+		return Pointer{}, fmt.Errorf("no valid pointer")
+	}
+	return history[len(history)-1], nil
+}
+
+// Publish composes the given Entries with es and submits them in order with
+// datastore.SubmitCommit and datastore.SubmitReveal. Entries with a nil
+// ChainID, such as Chain.FirstEntry, create a new chain.
+func Publish(ctx context.Context, c *factom.Client, es factom.EsAddress,
+	entries ...factom.Entry) error {
+	for _, e := range entries {
+		commit, reveal, txID, err := e.Compose(es)
+		if err != nil {
+			return err
+		}
+		if err := datastore.SubmitCommit(ctx, c, commit, &txID); err != nil {
+			return err
+		}
+		if err := datastore.SubmitReveal(ctx, c, reveal, e.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
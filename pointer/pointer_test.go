@@ -0,0 +1,92 @@
+package pointer
+
+import (
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestPointer(t *testing.T) {
+	require := require.New(t)
+
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	sk, err := factom.GenerateSK1Key()
+	require.NoError(err)
+	other, err := factom.GenerateSK1Key()
+	require.NoError(err)
+
+	ch := NewChain("whitepaper", sk.PublicKey(), factom.Bytes("test"))
+	assert.NotEqual(t, ch.ChainID,
+		NewChain("whitepaper", other.PublicKey(), factom.Bytes("test")).ChainID)
+
+	first := ch.FirstEntry()
+	reveal := marshal(t, &first)
+
+	_, err = ch.Update(other, nil, &factom.Bytes32{1}, nil)
+	require.Error(err, "not owner")
+
+	v1, err := ch.Update(sk, nil, &factom.Bytes32{1}, nil)
+	require.NoError(err)
+	p1, err := ch.ParseEntry(v1)
+	require.NoError(err)
+	p1.EntryHash = new(factom.Bytes32)
+	*p1.EntryHash = factom.ComputeEntryHash(marshal(t, &v1))
+
+	v2, err := ch.Update(sk, &p1, &factom.Bytes32{2},
+		[]byte(`{"note":"v2"}`))
+	require.NoError(err)
+
+	// A forged update whose signature does not match its content.
+	forged := v2
+	forged.Content = []byte(`{"version":2,"chainid":"` +
+		factom.Bytes32{3}.String() + `","prev":"` +
+		p1.EntryHash.String() + `"}`)
+	// A conflicting version 2 published after the valid one.
+	p1Copy := p1
+	fork, err := ch.Update(sk, &p1Copy, &factom.Bytes32{4}, nil)
+	require.NoError(err)
+
+	factomd.AddEBlock(reveal, marshal(t, &forged), marshal(t, &v1))
+	factomd.AddEBlock(marshal(t, &v2), marshal(t, &fork))
+
+	looked, err := Lookup(nil, c, &ch.ChainID)
+	require.NoError(err)
+	assert.Equal(t, ch, looked)
+
+	history, err := ch.History(nil, c)
+	require.NoError(err)
+	require.Len(history, 2)
+	assert.Equal(t, uint64(1), history[0].Version)
+	assert.Equal(t, factom.Bytes32{1}, *history[0].DataStore)
+	assert.Equal(t, uint64(2), history[1].Version)
+	assert.Equal(t, *history[0].EntryHash, *history[1].Prev)
+	assert.JSONEq(t, `{"note":"v2"}`, string(history[1].AppMetadata))
+
+	latest, err := ch.Resolve(nil, c)
+	require.NoError(err)
+	assert.Equal(t, factom.Bytes32{2}, *latest.DataStore)
+
+	// A pointer signed for a different chain is invalid.
+	otherCh := NewChain("other", sk.PublicKey())
+	replay := v1
+	replay.ChainID = &otherCh.ChainID
+	_, err = otherCh.ParseEntry(replay)
+	assert.Error(t, err)
+}
+
+func marshal(t *testing.T, e *factom.Entry) factom.Bytes {
+	if e.ChainID == nil {
+		e.ChainID = new(factom.Bytes32)
+		*e.ChainID = factom.ComputeChainID(e.ExtIDs)
+	}
+	data, err := e.MarshalBinary()
+	require.NoError(t, err)
+	return data
+}
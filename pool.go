@@ -0,0 +1,151 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Pool is a set of factomd endpoints. Requests made through a Pool are spread
+// across its endpoints and fail over to the next endpoint on any error,
+// including missing or invalid data, so that a single slow, out of sync, or
+// malicious factomd cannot stall or corrupt a download.
+//
+// Every Entry is still verified against its hash, so the endpoints need not be
+// trusted. Endpoints that fail repeatedly are skipped for a while, unless all
+// endpoints are failing.
+//
+// Use a Pool with DownloadOptions.Pool and Pool.Lookup. A Pool is safe for
+// concurrent use.
+type Pool struct {
+	// Cooldown is how long an endpoint is skipped after MaxFailures
+	// consecutive failures. Both may be set before the Pool is first
+	// used.
+	Cooldown    time.Duration
+	MaxFailures uint
+
+	clients []*factom.Client
+
+	mu     sync.Mutex
+	health []Health
+	next   int
+}
+
+// Health describes the requests made to a Pool endpoint.
+type Health struct {
+	FactomdServer string
+
+	Requests, Failures  uint64
+	ConsecutiveFailures uint
+
+	LastError   error
+	LastFailure time.Time
+}
+
+// NewPool returns a Pool of the given clients.
+func NewPool(clients ...*factom.Client) *Pool {
+	p := &Pool{
+		Cooldown:    30 * time.Second,
+		MaxFailures: 3,
+		clients:     clients,
+		health:      make([]Health, len(clients)),
+	}
+	for i, c := range clients {
+		p.health[i].FactomdServer = c.FactomdServer
+	}
+	return p
+}
+
+// Health returns the current Health of each endpoint, in the order given to
+// NewPool.
+func (p *Pool) Health() []Health {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Health(nil), p.health...)
+}
+
+// order returns the indexes of the endpoints in the order they should be
+// tried: healthy endpoints first, starting with the next in rotation, followed
+// by any cooling down endpoints.
+func (p *Pool) order() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.clients)
+	start := p.next
+	p.next = (p.next + 1) % n
+	healthy := make([]int, 0, n)
+	var cooling []int
+	for i := 0; i < n; i++ {
+		j := (start + i) % n
+		h := p.health[j]
+		if h.ConsecutiveFailures >= p.MaxFailures &&
+			time.Since(h.LastFailure) < p.Cooldown {
+			cooling = append(cooling, j)
+			continue
+		}
+		healthy = append(healthy, j)
+	}
+	return append(healthy, cooling...)
+}
+
+func (p *Pool) record(i int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := &p.health[i]
+	h.Requests++
+	if err == nil {
+		h.ConsecutiveFailures = 0
+		return
+	}
+	h.Failures++
+	h.ConsecutiveFailures++
+	h.LastError = err
+	h.LastFailure = time.Now()
+}
+
+// Do calls fn with the client of each endpoint in turn until fn returns nil,
+// and returns the last error if all endpoints fail.
+//
+// Context cancellation is not considered a failure of the endpoint, and is
+// returned immediately.
+func (p *Pool) Do(ctx context.Context, fn func(c *factom.Client) error) error {
+	if len(p.clients) == 0 {
+		return fmt.Errorf("empty Pool")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var err error
+	for _, i := range p.order() {
+		err = fn(p.clients[i])
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		p.record(i, err)
+		if err == nil {
+			return nil
+		}
+		err = fmt.Errorf("%v: %w", p.clients[i].FactomdServer, err)
+	}
+	return err
+}
+
+// Lookup the Metadata for a given Data Store chainID using opts, failing over
+// to the next endpoint on any error.
+func (p *Pool) Lookup(ctx context.Context, opts ParseOptions,
+	chainID *factom.Bytes32) (Metadata, error) {
+	var m Metadata
+	err := p.Do(ctx, func(c *factom.Client) error {
+		var err error
+		m, err = opts.Lookup(ctx, c, chainID)
+		return err
+	})
+	return m, err
+}
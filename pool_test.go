@@ -0,0 +1,102 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestPool(t *testing.T) {
+	data, m, reveals := generateTestStore(t, 12*factom.EntryMaxDataLen, "")
+
+	newServer := func() *factomdtest.Server {
+		s := factomdtest.NewServer()
+		s.AddEBlock(reveals[0])
+		s.AddEntries(reveals[1:]...)
+		return s
+	}
+
+	good1, good2 := newServer(), newServer()
+	defer good1.Close()
+	defer good2.Close()
+
+	// An endpoint that is down.
+	down := factomdtest.NewServer()
+	down.Close()
+
+	// An endpoint that is missing the Data Store.
+	missing := factomdtest.NewServer()
+	defer missing.Close()
+
+	// An endpoint that returns the same garbage Entry for every request.
+	wrong := newServer()
+	defer wrong.Close()
+	garbage, err := factom.Entry{ChainID: m.Entry.ChainID,
+		Content: factom.Bytes("garbage")}.MarshalBinary()
+	require.NoError(t, err)
+	wrong.AddEntries(garbage)
+	rawData := wrong.Method("raw-data")
+	wrongParams, _ := json.Marshal(struct {
+		Hash factom.Bytes32 `json:"hash"`
+	}{factom.ComputeEntryHash(garbage)})
+	wrong.Handle("raw-data", func(ctx context.Context,
+		params json.RawMessage) interface{} {
+		return rawData(ctx, wrongParams)
+	})
+
+	t.Run("failover", func(t *testing.T) {
+		require := require.New(t)
+		p := NewPool(down.Client(), missing.Client(), wrong.Client(),
+			good1.Client())
+
+		m, err := p.Lookup(nil, ParseOptions{}, m.Entry.ChainID)
+		require.NoError(err)
+
+		buf := bytes.NewBuffer(nil)
+		require.NoError(DownloadOptions{Pool: p, Workers: 1}.Download(
+			nil, nil, m, buf))
+		require.Equal(data, buf.Bytes())
+
+		health := p.Health()
+		require.Len(health, 4)
+		// Unhealthy endpoints are skipped once they reach MaxFailures.
+		for _, h := range health[:3] {
+			assert.Equal(t, uint64(p.MaxFailures), h.Requests,
+				h.FactomdServer)
+			assert.Equal(t, h.Requests, h.Failures)
+			assert.Error(t, h.LastError)
+		}
+		_, dbECount := m.EntryCounts()
+		assert.Equal(t, uint64(1+1+dbECount), health[3].Requests)
+		assert.Zero(t, health[3].Failures)
+	})
+
+	t.Run("load balance", func(t *testing.T) {
+		require := require.New(t)
+		calls1, calls2 := good1.Calls("raw-data"), good2.Calls("raw-data")
+		p := NewPool(good1.Client(), good2.Client())
+
+		buf := bytes.NewBuffer(nil)
+		require.NoError(DownloadOptions{Pool: p}.Download(nil, nil, m, buf))
+		require.Equal(data, buf.Bytes())
+
+		assert.Greater(t, good1.Calls("raw-data")-calls1, 1)
+		assert.Greater(t, good2.Calls("raw-data")-calls2, 1)
+	})
+
+	t.Run("all fail", func(t *testing.T) {
+		p := NewPool(missing.Client(), wrong.Client())
+		assert.Error(t, DownloadOptions{Pool: p}.Download(
+			nil, nil, m, bytes.NewBuffer(nil)))
+
+		_, err := NewPool().Lookup(nil, ParseOptions{}, m.Entry.ChainID)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,87 @@
+package datastore
+
+import "sync"
+
+// Stages of progress reported to a ProgressFunc.
+const (
+	// StageGenerate counts the Entries processed by
+	// GenerateOptions.Generate, including reused Known Data Blocks, and
+	// the bytes of on chain data in the Data Blocks.
+	StageGenerate = "generate"
+
+	// StageDBI counts the Data Block Entry Hashes parsed from the DBI.
+	StageDBI = "dbi"
+
+	// StageDownload counts the Data Block Entries downloaded, and the
+	// bytes of on chain data in them.
+	StageDownload = "download"
+
+	// StageCommit counts the commits acknowledged by factomd.
+	StageCommit = "commit"
+
+	// StageReveal counts the reveals acknowledged by factomd.
+	StageReveal = "reveal"
+
+	// StageReceipt counts the Receipts retrieved by
+	// DownloadOptions.GetReceipts.
+	StageReceipt = "receipt"
+)
+
+// Progress describes how much of a Stage is complete.
+type Progress struct {
+	// One of the Stage constants.
+	Stage string
+
+	// The number of Entries, or hashes, that are done, out of the Total.
+	Done, Total int
+
+	// The number of bytes that are done, out of the TotalBytes, for
+	// StageGenerate and StageDownload only.
+	Bytes, TotalBytes uint64
+}
+
+// ProgressFunc is called each time progress is made. Calls are never
+// concurrent, but may come from different goroutines, and must return quickly
+// to avoid slowing down the operation.
+type ProgressFunc func(Progress)
+
+// serialized returns fn wrapped with a mutex, so that it may be shared by the
+// progress of multiple Stages that run concurrently, or nil if fn is nil.
+func (fn ProgressFunc) serialized() ProgressFunc {
+	if fn == nil {
+		return nil
+	}
+	var mu sync.Mutex
+	return func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		fn(p)
+	}
+}
+
+// progress tracks and reports the Progress of a single Stage. Stages that
+// run concurrently must share a ProgressFunc.serialized.
+type progress struct {
+	mu sync.Mutex
+	fn ProgressFunc
+	Progress
+}
+
+func newProgress(fn ProgressFunc, stage string,
+	total int, totalBytes uint64) *progress {
+	return &progress{fn: fn, Progress: Progress{Stage: stage,
+		Total: total, TotalBytes: totalBytes}}
+}
+
+// add n Entries and bytes to p and report it. It is safe to call on a nil
+// *progress.
+func (p *progress) add(n int, bytes uint64) {
+	if p == nil || p.fn == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Done += n
+	p.Bytes += bytes
+	p.fn(p.Progress)
+}
@@ -0,0 +1,80 @@
+package datastore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+// progressRecorder records all Progress and checks that it is monotonic.
+type progressRecorder map[string][]Progress
+
+func (r progressRecorder) Record(t *testing.T) ProgressFunc {
+	return func(p Progress) {
+		if prev := r[p.Stage]; len(prev) > 0 {
+			last := prev[len(prev)-1]
+			assert.Equal(t, last.Done+1, p.Done, p.Stage)
+			assert.LessOrEqual(t, last.Bytes, p.Bytes, p.Stage)
+		}
+		r[p.Stage] = append(r[p.Stage], p)
+	}
+}
+
+// AssertDone asserts that stage reported n steps and finished.
+func (r progressRecorder) AssertDone(t *testing.T, stage string, n int) {
+	ps := r[stage]
+	if assert.Len(t, ps, n, stage) {
+		last := ps[n-1]
+		assert.Equal(t, last.Total, last.Done, stage)
+		assert.Equal(t, last.TotalBytes, last.Bytes, stage)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	require := require.New(t)
+
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+
+	data := make([]byte, 5*factom.EntryMaxDataLen+1)
+	rand.Read(data)
+	dataHash := ComputeDataHash(data)
+
+	r := make(progressRecorder)
+	g, err := GenerateOptions{Progress: r.Record(t)}.Generate(nil, nil, es,
+		bytes.NewReader(data), nil, uint64(len(data)), &dataHash, nil)
+	require.NoError(err)
+	r.AssertDone(t, StageGenerate, len(g.Reveals))
+	assert.Equal(t, uint64(len(data)), r[StageGenerate][0].TotalBytes)
+
+	require.NoError(PublishOptions{Progress: r.Record(t)}.Publish(nil, c,
+		g.TxIDs, g.EntryHashes, g.Commits, g.Reveals))
+	r.AssertDone(t, StageCommit, len(g.Commits))
+	r.AssertDone(t, StageReveal, len(g.Reveals))
+	factomd.Confirm()
+
+	m, err := Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+	require.NoError(DownloadOptions{Progress: r.Record(t)}.Download(nil, c,
+		m, ioutil.Discard))
+	r.AssertDone(t, StageDBI, 6)
+	r.AssertDone(t, StageDownload, 6)
+
+	delete(r, StageDBI)
+	delete(r, StageDownload)
+	require.NoError(DownloadOptions{Progress: r.Record(t)}.DownloadRange(
+		nil, c, m, ioutil.Discard, 2*factom.EntryMaxDataLen, 10))
+	r.AssertDone(t, StageDBI, 3)
+	r.AssertDone(t, StageDownload, 1)
+}
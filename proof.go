@@ -0,0 +1,87 @@
+package datastore
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// ErrNoData is returned when attempting to download the data of a proof of
+// existence record, which is not stored on chain.
+var ErrNoData = errors.New("proof of existence record has no on chain data")
+
+// IsProof returns true if m is a proof of existence record, which declares the
+// data hash and size of some data, but no DBI, since the data is not stored on
+// chain.
+func (m Metadata) IsProof() bool { return m.Proof }
+
+// Proof generates the First Entry of a proof of existence record for data with
+// the given dataHash and size, in appNamespace.
+//
+// The record uses the same NameIDs as a Data Store, so its Chain ID may be
+// computed from the data and appNamespace, but it declares "proof" and omits
+// the "dbi-start", so that only the single First Entry is required. Use
+// Metadata.Verify to verify that some data matches the record.
+func Proof(es factom.EsAddress, dataHash *factom.Bytes32, size uint64,
+	appMetadata json.RawMessage, appNamespace ...factom.Bytes) (
+	Generated, error) {
+	if size == 0 {
+		return Generated{}, fmt.Errorf("invalid size")
+	}
+
+	nameIDs := NameIDs(dataHash, appNamespace...)
+	g := Generated{ChainID: factom.ComputeChainID(nameIDs)}
+
+	firstE := factom.Entry{ChainID: &g.ChainID, ExtIDs: nameIDs}
+	var err error
+	firstE.Content, err = json.Marshal(Metadata{
+		Version:     Version,
+		Size:        size,
+		Proof:       true,
+		AppMetadata: appMetadata,
+	})
+	if err != nil {
+		return Generated{}, err
+	}
+
+	reveal, err := firstE.MarshalBinary()
+	if err != nil {
+		return Generated{}, err
+	}
+	hash := factom.ComputeEntryHash(reveal)
+	commit, txID := factom.GenerateCommit(es, reveal, &hash, true)
+	cost, err := factom.EntryCost(len(reveal), true)
+	if err != nil {
+		return Generated{}, err
+	}
+
+	g.TxIDs = []factom.Bytes32{txID}
+	g.EntryHashes = []factom.Bytes32{hash}
+	g.Commits = []factom.Bytes{commit}
+	g.Reveals = []factom.Bytes{reveal}
+	g.TotalCost = uint(cost)
+	return g, nil
+}
+
+// Verify reads all data from r and verifies that it matches the Size and
+// sha256d DataHash of m. This works for both Data Stores and proof of
+// existence records, and does not require factomd.
+func (m Metadata) Verify(r io.Reader) error {
+	hash := sha256.New()
+	n, err := io.Copy(hash, r)
+	if err != nil {
+		return err
+	}
+	if uint64(n) != m.Size {
+		return fmt.Errorf("size mismatch: %v bytes, expected %v bytes",
+			n, m.Size)
+	}
+	if sha256.Sum256(hash.Sum(nil)) != *m.DataHash {
+		return fmt.Errorf("data hash mismatch")
+	}
+	return nil
+}
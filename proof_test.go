@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestProof(t *testing.T) {
+	require := require.New(t)
+
+	s := factomdtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+
+	data := []byte("the document")
+	dataHash := ComputeDataHash(data)
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+
+	_, err = Proof(es, &dataHash, 0, nil)
+	assert.Error(t, err, "zero size")
+
+	g, err := Proof(es, &dataHash, uint64(len(data)),
+		[]byte(`{"filename":"doc.txt"}`), factom.Bytes("notary"))
+	require.NoError(err)
+	assert.Equal(t, factom.ComputeChainID(
+		NameIDs(&dataHash, factom.Bytes("notary"))), g.ChainID)
+	require.Len(g.Reveals, 1)
+	expected, _ := factom.EntryCost(len(g.Reveals[0]), true)
+	assert.Equal(t, uint(expected), g.TotalCost)
+
+	require.NoError(g.Publish(nil, c))
+	s.Confirm()
+
+	m, err := ParseOptions{Strict: true}.Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+	assert.True(t, m.IsProof())
+	assert.Equal(t, dataHash, *m.DataHash)
+	assert.Equal(t, uint64(len(data)), m.Size)
+	assert.Equal(t, []factom.Bytes{factom.Bytes("notary")}, m.Namespace())
+	assert.JSONEq(t, `{"filename":"doc.txt"}`, string(m.AppMetadata))
+	assert.NotContains(t, string(m.Entry.Content), "dbi-start")
+
+	require.NoError(m.Verify(bytes.NewReader(data)))
+	assert.Error(t, m.Verify(bytes.NewReader(data[1:])), "size")
+	assert.Error(t, m.Verify(bytes.NewReader(
+		[]byte("The document"))), "hash")
+
+	assert.True(t, errors.Is(m.Download(nil, c, ioutil.Discard), ErrNoData))
+	dir, err := ioutil.TempDir("", "fds-proof")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.True(t, errors.Is(DownloadOptions{}.DownloadFile(nil, c, m,
+		filepath.Join(dir, "doc.txt")), ErrNoData))
+	_, err = Export(nil, c, &g.ChainID)
+	assert.True(t, errors.Is(err, ErrNoData))
+	_, err = Alias(es, m, nil, factom.Bytes("other"))
+	assert.Error(t, err)
+
+	// Only an explicit proof may omit the DBI, and it may not declare
+	// either a DBI or compression.
+	_, err = ParseEntry(newFirstEntry(`{"data-store":"1.0","size":10}`))
+	assert.EqualError(t, err, `Content: missing "dbi-start"`)
+	_, err = ParseEntry(newFirstEntry(
+		`{"data-store":"1.0","size":10,"proof":true,` + dbiStart + `}`))
+	assert.EqualError(t, err, `Content: "proof" with "dbi-start"`)
+	_, err = ParseEntry(newFirstEntry(
+		`{"data-store":"1.0","size":10,"proof":true,` +
+			`"compression":{"format":"gzip","size":10}}`))
+	assert.EqualError(t, err, `Content: "proof" with "compression"`)
+}
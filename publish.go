@@ -0,0 +1,161 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/AdamSLevy/retry"
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Ack statuses returned by factomd's "ack" API.
+const (
+	StatusUnknown         = "Unknown"
+	StatusNotConfirmed    = "NotConfirmed"
+	StatusTransactionACK  = "TransactionACK"
+	StatusDBlockConfirmed = "DBlockConfirmed"
+)
+
+// DefaultPublishPolicy is the retry.Policy used while waiting for commits and
+// reveals to be acknowledged by factomd.
+var DefaultPublishPolicy retry.Policy = retry.Randomize{Factor: .25,
+	Policy: retry.LimitTotal{Limit: 15 * time.Minute,
+		Policy: retry.Max{Cap: 5 * time.Second,
+			Policy: retry.Exponential{
+				Initial:    50 * time.Millisecond,
+				Multiplier: 1.25}}}}
+
+// Publish submits the commits and reveals returned by Generate to factomd.
+//
+// This is equivalent to PublishOptions{}.Publish.
+func Publish(ctx context.Context, c *factom.Client,
+	txIDs, entryHashes []factom.Bytes32, commits, reveals []factom.Bytes) error {
+	return PublishOptions{}.Publish(ctx, c, txIDs, entryHashes,
+		commits, reveals)
+}
+
+// PublishOptions control how Data Stores are published.
+type PublishOptions struct {
+	// Progress, if not nil, is called with StageCommit as each commit is
+	// acknowledged, and then with StageReveal as each reveal is
+	// acknowledged.
+	Progress ProgressFunc
+}
+
+// Publish submits the commits and reveals returned by Generate to factomd.
+//
+// All commits are submitted and acknowledged before any reveals are submitted
+// so that a Data Store cannot be censored after it is partially revealed.
+// Publish returns after all reveals have been acknowledged.
+//
+// Commits which factomd reports as repeated are considered successful, so
+// Publish may be safely called again after a partial failure.
+func (opts PublishOptions) Publish(ctx context.Context, c *factom.Client,
+	txIDs, entryHashes []factom.Bytes32, commits, reveals []factom.Bytes) error {
+
+	if len(txIDs) != len(commits) ||
+		len(entryHashes) != len(reveals) ||
+		len(commits) != len(reveals) {
+		return fmt.Errorf("mismatched number of commits and reveals")
+	}
+
+	p := newProgress(opts.Progress, StageCommit, len(commits), 0)
+	for i, commit := range commits {
+		if err := SubmitCommit(ctx, c, commit, &txIDs[i]); err != nil {
+			return fmt.Errorf("commit %v: %w", i, err)
+		}
+		p.add(1, 0)
+	}
+
+	p = newProgress(opts.Progress, StageReveal, len(reveals), 0)
+	for i, reveal := range reveals {
+		if err := SubmitReveal(ctx, c, reveal, &entryHashes[i]); err != nil {
+			return fmt.Errorf("reveal %v: %w", i, err)
+		}
+		p.add(1, 0)
+	}
+
+	return nil
+}
+
+// SubmitCommit submits commit to factomd and waits for the Entry Transaction
+// with the given txID to be acknowledged, using DefaultPublishPolicy.
+func SubmitCommit(ctx context.Context, c *factom.Client,
+	commit factom.Bytes, txID *factom.Bytes32) error {
+	return submit(ctx, c, func() error { return c.Commit(ctx, commit) },
+		txID, nil)
+}
+
+// SubmitReveal submits reveal to factomd and waits for the Entry with the
+// given entryHash to be acknowledged, using DefaultPublishPolicy.
+//
+// The Entry's commit must already be acknowledged.
+func SubmitReveal(ctx context.Context, c *factom.Client,
+	reveal factom.Bytes, entryHash *factom.Bytes32) error {
+	if len(reveal) < factom.EntryHeaderLen {
+		return fmt.Errorf("invalid reveal")
+	}
+	var chainID factom.Bytes32
+	copy(chainID[:], reveal[1:])
+	return submit(ctx, c, func() error { return c.Reveal(ctx, reveal) },
+		entryHash, &chainID)
+}
+
+func submit(ctx context.Context, c *factom.Client, send func() error,
+	hash, chainID *factom.Bytes32) error {
+	return retry.Run(ctx, DefaultPublishPolicy, nil, nil, func() error {
+		status, err := AckStatus(ctx, c, hash, chainID)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case StatusTransactionACK, StatusDBlockConfirmed:
+			return nil
+		case StatusNotConfirmed:
+			return fmt.Errorf("not yet acknowledged")
+		}
+
+		if err := send(); err != nil {
+			jErr, ok := err.(jsonrpc2.Error)
+			if !ok || jErr.Message != "Repeated Commit" {
+				return retry.ErrorStop(err)
+			}
+		}
+		return fmt.Errorf("submitted")
+	})
+}
+
+// AckStatus returns the status that factomd reports for the Entry Transaction
+// with the given hash, if chainID is nil, or otherwise the status of the
+// Entry with the given hash and chainID.
+//
+// See the Status constants for the possible values.
+func AckStatus(ctx context.Context, c *factom.Client,
+	hash, chainID *factom.Bytes32) (string, error) {
+	params := struct {
+		Hash    *factom.Bytes32 `json:"hash"`
+		ChainID string          `json:"chainid"`
+	}{Hash: hash, ChainID: "c"}
+	if chainID != nil {
+		params.ChainID = chainID.String()
+	}
+
+	type status struct {
+		Status string `json:"status"`
+	}
+	var res struct {
+		Commit status `json:"commitdata"`
+		Reveal status `json:"entrydata"`
+	}
+
+	if err := c.FactomdRequest(ctx, "ack", params, &res); err != nil {
+		return "", err
+	}
+
+	if chainID == nil {
+		return res.Commit.Status, nil
+	}
+	return res.Reveal.Status, nil
+}
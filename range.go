@@ -0,0 +1,187 @@
+package datastore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"golang.org/x/sync/errgroup"
+)
+
+// DownloadRange downloads only the Data Block Entries required to write length
+// bytes of the data, starting at offset, to data.
+//
+// This is equivalent to DownloadOptions{}.DownloadRange.
+func (m Metadata) DownloadRange(ctx context.Context, c *factom.Client,
+	data io.Writer, offset, length uint64) error {
+	return DownloadOptions{}.DownloadRange(ctx, c, m, data, offset, length)
+}
+
+// DownloadRange downloads only the Data Block Entries of m required to write
+// length bytes of the data, starting at offset, to data.
+//
+// Only Data Stores without compression, or using FormatGzipSeekable, are
+// supported, since other compressed data can only be decompressed from the
+// beginning. See Metadata.Seekable. For FormatGzipSeekable, only the Data
+// Blocks holding the frames that overlap the range are downloaded.
+//
+// Every Entry Hash is verified, and for FormatGzipSeekable each frame must
+// decompress to exactly its declared size. However, since only part of the
+// data is downloaded, the data written is NOT checked against the sha256d data
+// hash, so an invalid frame index may go undetected if its frames still
+// decompress to the declared sizes.
+func (opts DownloadOptions) DownloadRange(ctx context.Context,
+	c *factom.Client, m Metadata, data io.Writer, offset, length uint64) error {
+
+	if !m.Seekable() {
+		return fmt.Errorf("range requests are not supported for %q compression",
+			m.Compression.Format)
+	}
+	if length == 0 || offset >= m.Size || length > m.Size-offset {
+		return fmt.Errorf("invalid range")
+	}
+
+	if m.Compression == nil {
+		buf, err := opts.downloadCRange(ctx, c, m, offset, length)
+		if err != nil {
+			return err
+		}
+		_, err = data.Write(buf)
+		return err
+	}
+
+	// The indexes of the first and last frames in the range.
+	frameSize := m.Compression.FrameSize
+	first := offset / frameSize
+	last := (offset + length - 1) / frameSize
+
+	// The offset and length of the frames within the on chain data.
+	var cOffset, cLength uint64
+	for i, frame := range m.Compression.Frames[:last+1] {
+		if uint64(i) < first {
+			cOffset += frame
+		} else {
+			cLength += frame
+		}
+	}
+
+	cData, err := opts.downloadCRange(ctx, c, m, cOffset, cLength)
+	if err != nil {
+		return err
+	}
+
+	// Decompress each frame independently, so that an invalid frame index
+	// cannot shift the data.
+	skip := offset - first*frameSize
+	for i := first; i <= last; i++ {
+		frameLen := m.Size - i*frameSize
+		if frameLen > frameSize {
+			frameLen = frameSize
+		}
+		cFrame := cData[:m.Compression.Frames[i]]
+		cData = cData[len(cFrame):]
+		frame, err := decompressFrame(cFrame, frameLen)
+		if err != nil {
+			return fmt.Errorf("frame %v: %w", i, err)
+		}
+		frame = frame[skip:]
+		skip = 0
+		if uint64(len(frame)) > length {
+			frame = frame[:length]
+		}
+		if _, err := data.Write(frame); err != nil {
+			return err
+		}
+		length -= uint64(len(frame))
+	}
+	return nil
+}
+
+// decompressFrame decompresses the single gzip member cFrame, which must
+// consume all of cFrame and decompress to exactly size bytes.
+func decompressFrame(cFrame []byte, size uint64) ([]byte, error) {
+	br := bytes.NewReader(cFrame)
+	r, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	r.Multistream(false)
+	frame, err := ioutil.ReadAll(io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(frame)) != size {
+		return nil, fmt.Errorf("invalid frame size")
+	}
+	if br.Len() != 0 {
+		return nil, fmt.Errorf("invalid compressed frame size")
+	}
+	return frame, nil
+}
+
+// downloadCRange downloads only the Data Block Entries required to return
+// length bytes of the on chain data, starting at offset.
+func (opts DownloadOptions) downloadCRange(ctx context.Context,
+	c *factom.Client, m Metadata, offset, length uint64) ([]byte, error) {
+
+	// Get the on-chain size.
+	size := m.Size
+	if m.Compression != nil {
+		size = m.Compression.Size
+	}
+
+	// The indexes of the first and last Data Blocks in the range.
+	first := int(offset / factom.EntryMaxDataLen)
+	last := int((offset + length - 1) / factom.EntryMaxDataLen)
+
+	// The offsets into the data of the first and last Data Blocks.
+	start := uint64(first) * factom.EntryMaxDataLen
+	end := uint64(last+1) * factom.EntryMaxDataLen
+	if end > size {
+		end = size
+	}
+	buf := make([]byte, end-start)
+
+	dbEs := make(chan dataBlock, last-first+1)
+
+	// The DBI and Data Blocks are downloaded concurrently.
+	opts.Progress = opts.Progress.serialized()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, ctx := errgroup.WithContext(ctx)
+	opts.getDataBlocks(g, ctx, c, dbEs, newProgress(opts.Progress,
+		StageDownload, last-first+1, uint64(len(buf))), nil)
+
+	err := opts.traverseDBI(ctx, c, m, last+1,
+		func(i int, dbEHash factom.Bytes32) error {
+			if i < first {
+				return nil
+			}
+			dbE := factom.Entry{Hash: &dbEHash}
+			bufI := (i - first) * factom.EntryMaxDataLen
+			dbE.Content = buf[bufI:bufI]
+			dbEs <- dataBlock{Entry: dbE, I: i}
+			return nil
+		})
+	close(dbEs)
+
+	// Prefer any error from the workers, which cancels ctx and so may
+	// cause the DBI traversal to fail as well.
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[offset-start : offset-start+length], nil
+}
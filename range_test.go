@@ -0,0 +1,71 @@
+package datastore
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/AdamSLevy/jsonrpc2/v12"
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestDownloadRange(t *testing.T) {
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	data, m, reveals := generateTestStore(t, 5*factom.EntryMaxDataLen-100, "")
+	factomd.AddEntries(reveals...)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, m.Download(nil, c, buf))
+	assert.Equal(t, data, buf.Bytes())
+
+	for _, rng := range []struct{ Offset, Length int }{
+		{0, 1},
+		{0, len(data)},
+		{factom.EntryMaxDataLen - 1, 2},
+		{2 * factom.EntryMaxDataLen, factom.EntryMaxDataLen},
+		{len(data) - 10, 10},
+	} {
+		buf.Reset()
+		calls := factomd.Calls("raw-data")
+		require.NoError(t, m.DownloadRange(nil, c, buf,
+			uint64(rng.Offset), uint64(rng.Length)), rng)
+		assert.Equal(t, data[rng.Offset:rng.Offset+rng.Length],
+			buf.Bytes(), rng)
+
+		// Only the DBI and the required Data Blocks are downloaded.
+		first := rng.Offset / factom.EntryMaxDataLen
+		last := (rng.Offset + rng.Length - 1) / factom.EntryMaxDataLen
+		assert.Equal(t, 1+last-first+1,
+			factomd.Calls("raw-data")-calls, rng)
+	}
+
+	assert.Error(t, m.DownloadRange(nil, c, buf, uint64(len(data)), 1))
+	assert.Error(t, m.DownloadRange(nil, c, buf, 0, uint64(len(data)+1)))
+
+	_, m, _ = generateTestStore(t, 100, "gzip")
+	assert.Error(t, m.DownloadRange(nil, c, buf, 0, 1))
+}
+
+func TestDownloadRangeWorkerError(t *testing.T) {
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	data, m, reveals := generateTestStore(t,
+		(MaxDBIEHashCount+1)*factom.EntryMaxDataLen, "")
+	factomd.AddEntries(reveals...)
+	defer failFirstDataBlock(t, factomd, m, reveals)()
+
+	err := m.DownloadRange(nil, c, ioutil.Discard, 0, uint64(len(data)))
+	var jErr jsonrpc2.Error
+	require.True(t, errors.As(err, &jErr), err)
+	assert.Equal(t, jsonrpc2.ErrorCodeInvalidParams, jErr.Code)
+}
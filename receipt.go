@@ -0,0 +1,274 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Receipt proves that an Entry was included in an EBlock, and that the EBlock
+// was included in a DBlock, using the Merkle proofs reported by factomd's
+// "receipt" API. The DBlock header is included so that the Timestamp and
+// Height of the DBlock may be verified offline.
+//
+// The DBlockKeyMR may in turn be proven to exist at a given time by the
+// Bitcoin anchor, if factomd reports one.
+type Receipt struct {
+	EntryHash    factom.Bytes32 `json:"entryhash"`
+	MerkleBranch []MerkleNode   `json:"merklebranch"`
+	EBlockKeyMR  factom.Bytes32 `json:"entryblockkeymr"`
+	DBlockKeyMR  factom.Bytes32 `json:"directoryblockkeymr"`
+
+	// The raw DBlock header, whose hash is the left side of the last
+	// MerkleNode.
+	DBlockHeader factom.Bytes `json:"directoryblockheader"`
+
+	// The Bitcoin anchor of the DBlock, if any.
+	BitcoinTransactionHash *factom.Bytes32 `json:"bitcointransactionhash,omitempty"`
+	BitcoinBlockHash       *factom.Bytes32 `json:"bitcoinblockhash,omitempty"`
+}
+
+// MerkleNode is a node of a Merkle proof, where Top is sha256(Left|Right).
+type MerkleNode struct {
+	Left  factom.Bytes32 `json:"left"`
+	Right factom.Bytes32 `json:"right"`
+	Top   factom.Bytes32 `json:"top"`
+}
+
+// GetReceipt queries factomd for the Receipt of the confirmed Entry with the
+// given hash, and the header of its DBlock, and then verifies it.
+func GetReceipt(ctx context.Context, c *factom.Client,
+	hash *factom.Bytes32) (Receipt, error) {
+	params := struct {
+		Hash *factom.Bytes32 `json:"hash"`
+	}{hash}
+	var res struct {
+		Receipt struct {
+			Entry struct {
+				Hash factom.Bytes32 `json:"entryhash"`
+			} `json:"entry"`
+			MerkleBranch []MerkleNode   `json:"merklebranch"`
+			EBlockKeyMR  factom.Bytes32 `json:"entryblockkeymr"`
+			DBlockKeyMR  factom.Bytes32 `json:"directoryblockkeymr"`
+
+			// factomd omits these or reports them as empty strings.
+			BitcoinTransactionHash string `json:"bitcointransactionhash"`
+			BitcoinBlockHash       string `json:"bitcoinblockhash"`
+		} `json:"receipt"`
+	}
+	if err := c.FactomdRequest(ctx, "receipt", params, &res); err != nil {
+		return Receipt{}, err
+	}
+	r := Receipt{
+		EntryHash:    res.Receipt.Entry.Hash,
+		MerkleBranch: res.Receipt.MerkleBranch,
+		EBlockKeyMR:  res.Receipt.EBlockKeyMR,
+		DBlockKeyMR:  res.Receipt.DBlockKeyMR,
+	}
+	var err error
+	if r.BitcoinTransactionHash, err = parseAnchor(
+		res.Receipt.BitcoinTransactionHash); err != nil {
+		return Receipt{}, err
+	}
+	if r.BitcoinBlockHash, err = parseAnchor(
+		res.Receipt.BitcoinBlockHash); err != nil {
+		return Receipt{}, err
+	}
+
+	db := factom.DBlock{KeyMR: &r.DBlockKeyMR}
+	if err := db.Get(ctx, c); err != nil {
+		return Receipt{}, err
+	}
+	data, err := db.MarshalBinary()
+	if err != nil {
+		return Receipt{}, err
+	}
+	r.DBlockHeader = data[:factom.DBlockHeaderLen]
+
+	if r.EntryHash != *hash {
+		return Receipt{}, fmt.Errorf("invalid receipt: wrong Entry Hash")
+	}
+	if err := r.Verify(); err != nil {
+		return Receipt{}, err
+	}
+	return r, nil
+}
+
+func parseAnchor(hash string) (*factom.Bytes32, error) {
+	if len(hash) == 0 {
+		return nil, nil
+	}
+	anchor := new(factom.Bytes32)
+	if err := anchor.UnmarshalText([]byte(hash)); err != nil {
+		return nil, fmt.Errorf("invalid receipt: %w", err)
+	}
+	return anchor, nil
+}
+
+// Verify the Merkle proofs of r from the EntryHash, through the EBlockKeyMR,
+// to the DBlockKeyMR, and the DBlockHeader, without factomd.
+func (r Receipt) Verify() error {
+	if len(r.MerkleBranch) == 0 {
+		return fmt.Errorf("invalid receipt: empty Merkle branch")
+	}
+	hash := r.EntryHash
+	var eBlock bool
+	for _, node := range r.MerkleBranch {
+		if hash != node.Left && hash != node.Right {
+			return fmt.Errorf("invalid receipt: broken Merkle branch")
+		}
+		data := make([]byte, 0, len(node.Left)+len(node.Right))
+		data = append(append(data, node.Left[:]...), node.Right[:]...)
+		if sha256.Sum256(data) != node.Top {
+			return fmt.Errorf("invalid receipt: invalid Merkle node")
+		}
+		hash = node.Top
+		if hash == r.EBlockKeyMR {
+			eBlock = true
+		}
+	}
+	if !eBlock {
+		return fmt.Errorf("invalid receipt: EBlock KeyMR not in Merkle branch")
+	}
+	if hash != r.DBlockKeyMR {
+		return fmt.Errorf("invalid receipt: wrong DBlock KeyMR")
+	}
+	if r.ChainID() == nil {
+		return fmt.Errorf("invalid receipt: EBlock not in DBlock")
+	}
+
+	// The DBlock KeyMR is sha256(headerHash|bodyMR).
+	if len(r.DBlockHeader) != factom.DBlockHeaderLen ||
+		factom.ComputeDBlockHeaderHash(r.DBlockHeader) !=
+			r.MerkleBranch[len(r.MerkleBranch)-1].Left {
+		return fmt.Errorf("invalid receipt: invalid DBlock header")
+	}
+	return nil
+}
+
+// ChainID returns the Chain ID of the EBlock of r, which is proven by the
+// MerkleNode that hashes it with the EBlockKeyMR as a DBlock element, or nil
+// if there is no such MerkleNode.
+func (r Receipt) ChainID() *factom.Bytes32 {
+	for i, node := range r.MerkleBranch[:len(r.MerkleBranch)-1] {
+		if node.Top != r.EBlockKeyMR {
+			continue
+		}
+		next := r.MerkleBranch[i+1]
+		if next.Right != r.EBlockKeyMR {
+			return nil
+		}
+		chainID := next.Left
+		return &chainID
+	}
+	return nil
+}
+
+// Timestamp returns the Timestamp of the DBlock from the DBlockHeader.
+func (r Receipt) Timestamp() time.Time {
+	if len(r.DBlockHeader) != factom.DBlockHeaderLen {
+		return time.Time{}
+	}
+	i := 1 + 4 + 32 + 32 + 32
+	return time.Unix(int64(binary.BigEndian.Uint32(r.DBlockHeader[i:]))*60, 0)
+}
+
+// Height returns the Height of the DBlock from the DBlockHeader.
+func (r Receipt) Height() uint32 {
+	if len(r.DBlockHeader) != factom.DBlockHeaderLen {
+		return 0
+	}
+	i := 1 + 4 + 32 + 32 + 32 + 4
+	return binary.BigEndian.Uint32(r.DBlockHeader[i:])
+}
+
+// GetReceipts returns the Receipt of the First Entry of m, and if all is true,
+// the Receipts of each DBI Entry and unique Data Block Entry, in the same order
+// as Archive.Reveals.
+func (opts DownloadOptions) GetReceipts(ctx context.Context, c *factom.Client,
+	m Metadata, all bool) ([]Receipt, error) {
+	hashes := []factom.Bytes32{*m.Entry.Hash}
+	if all {
+		var err error
+		if hashes, _, err = opts.getEntryHashes(ctx, c, m); err != nil {
+			return nil, err
+		}
+	}
+
+	p := newProgress(opts.Progress, StageReceipt, len(hashes), 0)
+	receipts := make([]Receipt, len(hashes))
+	for i := range hashes {
+		get := func(c *factom.Client) error {
+			var err error
+			receipts[i], err = GetReceipt(ctx, c, &hashes[i])
+			return err
+		}
+		var err error
+		if opts.Pool != nil {
+			err = opts.Pool.Do(ctx, get)
+		} else {
+			err = get(c)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("receipt %v: %w", hashes[i], err)
+		}
+		p.add(1, 0)
+	}
+	return receipts, nil
+}
+
+// VerifyReceipts verifies receipts without factomd, and that they prove that
+// the First Entry of a, followed by any number of its other Entries, are in
+// the Chain of each Entry.
+func (a Archive) VerifyReceipts(receipts []Receipt) error {
+	if len(receipts) == 0 {
+		return fmt.Errorf("no receipts")
+	}
+	entries, err := archiveEntries(a.Reveals)
+	if err != nil {
+		return err
+	}
+	for i, r := range receipts {
+		if err := r.Verify(); err != nil {
+			return fmt.Errorf("receipt %v: %w", i, err)
+		}
+		reveal, ok := entries[r.EntryHash]
+		if !ok {
+			return fmt.Errorf("receipt %v: Entry %v not in archive",
+				i, r.EntryHash)
+		}
+		// DBI and Data Block Entries may be on another Chain.
+		if len(reveal) < factom.EntryHeaderLen ||
+			!bytes.Equal(r.ChainID()[:], reveal[1:1+32]) {
+			return fmt.Errorf("receipt %v: wrong Chain ID", i)
+		}
+	}
+	if receipts[0].EntryHash != *a.Entry.Hash {
+		return fmt.Errorf("receipt 0: not the First Entry")
+	}
+	return nil
+}
+
+// WriteReceipts writes receipts to w as JSON.
+func WriteReceipts(w io.Writer, receipts []Receipt) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(receipts)
+}
+
+// ReadReceipts reads receipts written by WriteReceipts from r. The receipts
+// are not verified.
+func ReadReceipts(r io.Reader) ([]Receipt, error) {
+	var receipts []Receipt
+	if err := json.NewDecoder(r).Decode(&receipts); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
@@ -0,0 +1,95 @@
+package datastore
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestReceipts(t *testing.T) {
+	require := require.New(t)
+
+	_, m, reveals := generateTestStore(t, 5*factom.EntryMaxDataLen, "",
+		factom.Bytes("test"))
+	_, _, other := generateTestStore(t, 100, "")
+
+	s := factomdtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+	s.AddEBlock(other...)
+	s.AddEBlock(reveals[:2]...)
+	s.AddEBlock(reveals[2:]...)
+
+	_, err := GetReceipt(nil, c, m.DBIStart)
+	require.NoError(err)
+	_, err = GetReceipt(nil, c, &factom.Bytes32{1})
+	assert.Error(t, err, "missing")
+
+	receipts, err := DownloadOptions{}.GetReceipts(nil, c, m, false)
+	require.NoError(err)
+	require.Len(receipts, 1)
+	assert.Equal(t, *m.Entry.Hash, receipts[0].EntryHash)
+
+	receipts, err = DownloadOptions{}.GetReceipts(nil, c, m, true)
+	require.NoError(err)
+	require.Len(receipts, len(reveals))
+	for i, r := range receipts {
+		assert.Equal(t, factom.ComputeEntryHash(reveals[i]), r.EntryHash)
+		assert.NoError(t, r.Verify(), i)
+		assert.Equal(t, m.Entry.ChainID, r.ChainID(), i)
+		height := uint32(1)
+		if i >= 2 {
+			height = 2
+		}
+		assert.Equal(t, height, r.Height(), i)
+		assert.Equal(t, factomdtest.Genesis.Add(
+			time.Duration(height)*10*time.Minute).Unix(),
+			r.Timestamp().Unix(), i)
+	}
+
+	a, err := Export(nil, c, m.Entry.ChainID)
+	require.NoError(err)
+	require.NoError(a.VerifyReceipts(receipts))
+	require.NoError(a.VerifyReceipts(receipts[:1]))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(WriteReceipts(buf, receipts))
+	read, err := ReadReceipts(buf)
+	require.NoError(err)
+	assert.Equal(t, receipts, read)
+	require.NoError(a.VerifyReceipts(read))
+
+	assert.Error(t, a.VerifyReceipts(nil), "none")
+	assert.Error(t, a.VerifyReceipts(receipts[1:]), "not First Entry")
+
+	otherE := factom.Entry{}
+	require.NoError(otherE.UnmarshalBinary(other[0]))
+	otherR, err := GetReceipt(nil, c, otherE.Hash)
+	require.NoError(err)
+	assert.Error(t, a.VerifyReceipts(append(receipts[:1:1], otherR)),
+		"other chain")
+
+	// Any change to a Receipt is detected.
+	tamper := []func(r *Receipt){
+		func(r *Receipt) { r.EntryHash[0]++ },
+		func(r *Receipt) { r.MerkleBranch[0].Left[0]++ },
+		func(r *Receipt) { r.MerkleBranch[len(r.MerkleBranch)-1].Top[0]++ },
+		func(r *Receipt) { r.MerkleBranch = r.MerkleBranch[:1] },
+		func(r *Receipt) { r.EBlockKeyMR[0]++ },
+		func(r *Receipt) { r.DBlockKeyMR[0]++ },
+		func(r *Receipt) { r.DBlockHeader[len(r.DBlockHeader)-5]++ },
+	}
+	for i, tamper := range tamper {
+		r := read[0]
+		r.MerkleBranch = append([]MerkleNode(nil), r.MerkleBranch...)
+		r.DBlockHeader = append(factom.Bytes(nil), r.DBlockHeader...)
+		tamper(&r)
+		assert.Error(t, r.Verify(), i)
+	}
+}
@@ -0,0 +1,84 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Replay generates new commits, paid for by es, for the reveals of a complete
+// Data Store, such as the Reveals of an Archive or Generated, so that the
+// identical Data Store, with the same Chain ID and Entry Hashes, may be
+// published on another network.
+//
+// The first reveal must be the First Entry. The reveals are verified as with
+// Import, so they must include every Entry of the Data Store, including any
+// reused Data Blocks. Repeated reveals are only included once.
+//
+// Only the First Entry creates a Chain. Any other Entries on other Chains, such
+// as a GenerateOptions.PoolChainID or the Chains of reused Known Data Blocks,
+// are committed as Entries, so those Chains must already exist on the target
+// network.
+func Replay(es factom.EsAddress, reveals []factom.Bytes) (Generated, error) {
+	unique := make([]factom.Bytes, 0, len(reveals))
+	hashes := make([]factom.Bytes32, 0, len(reveals))
+	seen := make(map[factom.Bytes32]struct{}, len(reveals))
+	for _, reveal := range reveals {
+		hash := factom.ComputeEntryHash(reveal)
+		if _, ok := seen[hash]; ok {
+			continue
+		}
+		seen[hash] = struct{}{}
+		unique = append(unique, reveal)
+		hashes = append(hashes, hash)
+	}
+
+	m, err := verifyArchive(unique)
+	if err != nil {
+		return Generated{}, err
+	}
+
+	g := Generated{
+		ChainID:     *m.Entry.ChainID,
+		TxIDs:       make([]factom.Bytes32, len(unique)),
+		EntryHashes: hashes,
+		Commits:     make([]factom.Bytes, len(unique)),
+		Reveals:     unique,
+	}
+	for i, reveal := range unique {
+		// Only the First Entry creates the chain.
+		newChain := i == 0
+		g.Commits[i], g.TxIDs[i] = factom.GenerateCommit(es, reveal,
+			&hashes[i], newChain)
+		cost, err := factom.EntryCost(len(reveal), newChain)
+		if err != nil {
+			return Generated{}, err
+		}
+		g.TotalCost += uint(cost)
+	}
+	return g, nil
+}
+
+// Verify that the Data Store g has been published on the network of c, along
+// with all of its Entries.
+//
+// The Data Store is downloaded and verified as with Export, so its First Entry
+// must be confirmed in an Entry Block.
+func (g Generated) Verify(ctx context.Context, c *factom.Client) error {
+	a, err := Export(ctx, c, &g.ChainID)
+	if err != nil {
+		return err
+	}
+	published := make(map[factom.Bytes32]struct{}, len(a.Reveals))
+	for _, reveal := range a.Reveals {
+		published[factom.ComputeEntryHash(reveal)] = struct{}{}
+	}
+	for _, hash := range g.EntryHashes {
+		if _, ok := published[hash]; !ok {
+			return fmt.Errorf("Entry %v is not part of the Data Store",
+				hash)
+		}
+	}
+	return nil
+}
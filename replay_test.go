@@ -0,0 +1,58 @@
+package datastore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestReplay(t *testing.T) {
+	require := require.New(t)
+
+	// The Data Store exists on the source network.
+	data, m, reveals := generateTestStore(t, 5*factom.EntryMaxDataLen, "gzip",
+		factom.Bytes("test"))
+
+	target := factomdtest.NewServer()
+	defer target.Close()
+	c := target.Client()
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+
+	_, err = Replay(es, reveals[:len(reveals)-1])
+	assert.Error(t, err, "incomplete")
+
+	// Repeated reveals are only committed once.
+	g, err := Replay(es, append(reveals, reveals[len(reveals)-1]))
+	require.NoError(err)
+	assert.Equal(t, *m.Entry.ChainID, g.ChainID)
+	assert.Equal(t, reveals, g.Reveals)
+	require.Len(g.EntryHashes, len(reveals))
+	for i, reveal := range reveals {
+		assert.Equal(t, factom.ComputeEntryHash(reveal), g.EntryHashes[i])
+	}
+	require.Len(g.Commits, len(reveals))
+	require.Len(g.TxIDs, len(reveals))
+
+	require.NoError(g.Publish(nil, c))
+	assert.Error(t, g.Verify(nil, c), "not confirmed")
+	target.Confirm()
+	require.NoError(g.Verify(nil, c))
+
+	m, err = Lookup(nil, c, &g.ChainID)
+	require.NoError(err)
+	buf := bytes.NewBuffer(nil)
+	require.NoError(m.Download(nil, c, buf))
+	assert.Equal(t, data, buf.Bytes())
+
+	// Verify fails if an Entry is not part of the published Data Store.
+	_, _, other := generateTestStore(t, 100, "")
+	g.EntryHashes = append(g.EntryHashes, factom.ComputeEntryHash(other[1]))
+	assert.Error(t, g.Verify(nil, c))
+}
@@ -0,0 +1,265 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// Suffixes appended to the path passed to DownloadFile for the files that
+// hold the state of a partial download.
+const (
+	PartialSuffix = ".fds-part"
+	StateSuffix   = ".fds-state"
+)
+
+// DownloadFile downloads the Data Store described by m to the file at path,
+// such that the download may be resumed if it is interrupted.
+//
+// The on chain data is written to path+PartialSuffix as each Data Block is
+// downloaded. The DBI, and the index of each Data Block once it is written,
+// are saved to path+StateSuffix. If these files remain from a previous call
+// for the same Data Store, only the missing Data Blocks are downloaded.
+//
+// Once all Data Blocks are written, the data is decompressed, if necessary,
+// and its sha256d data hash is verified before it is saved to path, and the
+// partial download files are removed. If the data cannot be decompressed or
+// the data hash is invalid, the partial download files are also removed, so
+// that the next call starts over.
+func (opts DownloadOptions) DownloadFile(ctx context.Context, c *factom.Client,
+	m Metadata, path string) error {
+	if m.IsProof() {
+		return ErrNoData
+	}
+	partPath, statePath := path+PartialSuffix, path+StateSuffix
+
+	state, err := os.OpenFile(statePath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer state.Close()
+
+	dbi, done, err := loadState(state, m)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(partPath); os.IsNotExist(err) {
+		// Without the partial data, the saved state is useless.
+		dbi = nil
+	}
+	if dbi == nil {
+		// Start over by saving the DBI.
+		if dbi, err = opts.GetDBI(ctx, c, m); err != nil {
+			return err
+		}
+		done = make([]bool, len(dbi))
+		if err := saveState(state, m, dbi); err != nil {
+			return err
+		}
+		if err := os.Remove(partPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	part, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	// Get the on-chain size.
+	size := m.Size
+	if m.Compression != nil {
+		size = m.Compression.Size
+	}
+
+	// Download the missing Data Blocks, and record each once it is
+	// written.
+	var n int
+	var nBytes uint64
+	for i := range done {
+		if done[i] {
+			continue
+		}
+		n++
+		nBytes += factom.EntryMaxDataLen
+		if i == len(done)-1 {
+			nBytes -= uint64(len(done))*factom.EntryMaxDataLen - size
+		}
+	}
+	var mu sync.Mutex
+	record := make([]byte, 4)
+	if err := opts.writeDataBlocks(ctx, c, m, part, n, nBytes,
+		func(ctx context.Context,
+			fn func(int, factom.Bytes32) error) error {
+			for i, dbEHash := range dbi {
+				if done[i] {
+					continue
+				}
+				if err := fn(i, dbEHash); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		func(i int) error {
+			mu.Lock()
+			defer mu.Unlock()
+			// Ensure the Data Block is on disk before it is
+			// recorded as done.
+			if err := part.Sync(); err != nil {
+				return err
+			}
+			binary.BigEndian.PutUint32(record, uint32(i))
+			_, err := state.Write(record)
+			return err
+		}); err != nil {
+		return err
+	}
+
+	// Decompress, if necessary, and verify the data hash.
+	if err := writeFile(path, io.NewSectionReader(part, 0, int64(size)),
+		m); err != nil {
+		var dErr invalidDataError
+		if errors.As(err, &dErr) {
+			os.Remove(partPath)
+			os.Remove(statePath)
+		}
+		return err
+	}
+	part.Close()
+	state.Close()
+	os.Remove(partPath)
+	os.Remove(statePath)
+	return nil
+}
+
+var errInvalidDataHash = errors.New("invalid data hash")
+
+// invalidDataError reports that the on chain data could not be decompressed or
+// does not match the data hash.
+type invalidDataError struct{ error }
+
+func (err invalidDataError) Unwrap() error { return err.error }
+
+// invalidDataReader wraps any error, other than io.EOF, from reading the
+// decompressed data in invalidDataError.
+type invalidDataReader struct{ io.Reader }
+
+func (r invalidDataReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = invalidDataError{err}
+	}
+	return n, err
+}
+
+// writeFile decompresses, if necessary, the on chain data read from cData,
+// and writes it to a new file at path, after verifying the data hash. If an
+// error is returned, the file is removed. Decompression and data hash errors
+// are returned as invalidDataError.
+func writeFile(path string, cData io.Reader, m Metadata) (err error) {
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	data := cData
+	if m.Compression != nil {
+		r, err := m.Compression.newReader(data)
+		if err != nil {
+			return invalidDataError{err}
+		}
+		defer r.Close()
+		data = invalidDataReader{r}
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(hash, f), data)
+	if err != nil {
+		return err
+	}
+	if uint64(n) != m.Size || *m.DataHash != sha256.Sum256(hash.Sum(nil)) {
+		return invalidDataError{errInvalidDataHash}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// loadState reads the DBI, and which Data Blocks are done, for m from the
+// state file f. If f is empty, or not for m, a nil DBI is returned.
+//
+// The state file begins with the data hash and DBI Start Entry Hash of m,
+// followed by the DBI, and then the big endian uint32 index of each Data Block
+// that has been written. Any trailing incomplete index is truncated from f.
+func loadState(f *os.File, m Metadata) ([]factom.Bytes32, []bool, error) {
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, dbECount := m.EntryCounts()
+	i := 32 + 32 + dbECount*32
+	if len(data) < i ||
+		!bytes.Equal(data[:32], m.DataHash[:]) ||
+		!bytes.Equal(data[32:64], m.DBIStart[:]) {
+		return nil, nil, nil
+	}
+
+	dbi := make([]factom.Bytes32, dbECount)
+	for j := range dbi {
+		copy(dbi[j][:], data[64+j*32:])
+	}
+
+	done := make([]bool, dbECount)
+	for ; i+4 <= len(data); i += 4 {
+		j := binary.BigEndian.Uint32(data[i : i+4])
+		if int(j) >= dbECount {
+			return nil, nil, nil
+		}
+		done[j] = true
+	}
+
+	if err := f.Truncate(int64(i)); err != nil {
+		return nil, nil, err
+	}
+	if _, err := f.Seek(int64(i), io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	return dbi, done, nil
+}
+
+// saveState replaces the contents of the state file f with the header and dbi
+// for m. See loadState.
+func saveState(f *os.File, m Metadata, dbi []factom.Bytes32) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data := make([]byte, 0, 64+len(dbi)*32)
+	data = append(data, m.DataHash[:]...)
+	data = append(data, m.DBIStart[:]...)
+	for _, dbEHash := range dbi {
+		data = append(data, dbEHash[:]...)
+	}
+	_, err := f.Write(data)
+	return err
+}
@@ -0,0 +1,95 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestDownloadFile(t *testing.T) {
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	dir, err := ioutil.TempDir("", "fds-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, format := range []string{"", "gzip"} {
+		format := format
+		t.Run(format, func(t *testing.T) {
+			require := require.New(t)
+			data, m, reveals := generateTestStore(t,
+				30*factom.EntryMaxDataLen+100, format)
+			factomd.AddEntries(reveals...)
+			path := filepath.Join(dir, "data"+format)
+			opts := DownloadOptions{Workers: 1}
+
+			// Interrupt the download at the last Data Block.
+			last := factom.ComputeEntryHash(reveals[len(reveals)-1])
+			factomd.RemoveEntries(last)
+			require.Error(opts.DownloadFile(nil, c, m, path))
+			assert.FileExists(t, path+PartialSuffix)
+			assert.FileExists(t, path+StateSuffix)
+			_, err := os.Stat(path)
+			assert.True(t, os.IsNotExist(err))
+
+			// Only the last Data Block is downloaded.
+			factomd.AddEntries(reveals[len(reveals)-1])
+			calls := factomd.Calls("raw-data")
+			require.NoError(opts.DownloadFile(nil, c, m, path))
+			assert.Equal(t, 1, factomd.Calls("raw-data")-calls)
+
+			written, err := ioutil.ReadFile(path)
+			require.NoError(err)
+			require.Equal(data, written)
+			for _, suffix := range []string{PartialSuffix, StateSuffix} {
+				_, err := os.Stat(path + suffix)
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+
+	for _, test := range []struct{ Format, Err string }{
+		{"", "invalid data hash"},
+		{"gzip", "gzip: invalid header"},
+	} {
+		test := test
+		t.Run("corrupt "+test.Format, func(t *testing.T) {
+			require := require.New(t)
+			data, m, reveals := generateTestStore(t,
+				5*factom.EntryMaxDataLen, test.Format)
+			factomd.AddEntries(reveals...)
+			path := filepath.Join(dir, "corrupt"+test.Format)
+			opts := DownloadOptions{Workers: 1}
+
+			last := factom.ComputeEntryHash(reveals[len(reveals)-1])
+			factomd.RemoveEntries(last)
+			require.Error(opts.DownloadFile(nil, c, m, path))
+			factomd.AddEntries(reveals[len(reveals)-1])
+
+			// Corrupt a Data Block that was already written.
+			require.NoError(ioutil.WriteFile(path+PartialSuffix,
+				[]byte("corrupt"), 0644))
+			assert.EqualError(t, opts.DownloadFile(nil, c, m, path),
+				test.Err)
+			for _, suffix := range []string{PartialSuffix, StateSuffix} {
+				_, err := os.Stat(path + suffix)
+				assert.True(t, os.IsNotExist(err))
+			}
+
+			// The next attempt starts over.
+			require.NoError(opts.DownloadFile(nil, c, m, path))
+			written, err := ioutil.ReadFile(path)
+			require.NoError(err)
+			require.Equal(data, written)
+		})
+	}
+}
@@ -0,0 +1,579 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// JSONSchema returns an AppMetadataDecoder that validates the AppMetadata
+// against the given JSON Schema document. The decoded value is the AppMetadata
+// unmarshaled into an interface{}, with numbers as json.Number.
+//
+// A missing AppMetadata is validated as JSON null, so a schema that requires
+// an object also requires that the AppMetadata is present.
+//
+// Only the following validation keywords are supported, which is sufficient
+// for describing the shape of typical application metadata: "type", "enum",
+// "const", "properties", "required", "additionalProperties",
+// "patternProperties", "minProperties", "maxProperties", "items",
+// "minItems", "maxItems", "uniqueItems", "minLength", "maxLength", "pattern",
+// "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf",
+// "allOf", "anyOf", "oneOf", and "not". Annotation keywords, such as "title",
+// "description", and "$schema", are ignored. Any other keyword results in an
+// error so that a schema is never silently only partially enforced.
+func JSONSchema(schema []byte) (AppMetadataDecoder, error) {
+	s, err := parseSchema(schema)
+	if err != nil {
+		return nil, fmt.Errorf("JSON Schema: %w", err)
+	}
+	return func(appMetadata json.RawMessage) (interface{}, error) {
+		if appMetadata == nil {
+			appMetadata = json.RawMessage("null")
+		}
+		v, err := decodeJSON(appMetadata)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.validate("", v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}, nil
+}
+
+// schema is a parsed JSON Schema.
+type schema struct {
+	// Boolean schemas are either always or never valid.
+	boolean *bool
+
+	types []string
+	enum  []interface{}
+	cnst  *interface{}
+
+	properties           map[string]*schema
+	patternProperties    map[*regexp.Regexp]*schema
+	additionalProperties *schema
+	required             []string
+	minProperties        *int
+	maxProperties        *int
+
+	items       *schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*schema
+	anyOf []*schema
+	oneOf []*schema
+	not   *schema
+}
+
+// annotations are schema keywords that do not affect validation.
+var annotations = map[string]struct{}{
+	"$schema": {}, "$id": {}, "$comment": {}, "title": {}, "description": {},
+	"default": {}, "examples": {}, "format": {},
+}
+
+func parseSchema(data []byte) (*schema, error) {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return newSchema(v)
+}
+
+func newSchema(v interface{}) (*schema, error) {
+	var s schema
+	switch v := v.(type) {
+	case bool:
+		s.boolean = &v
+		return &s, nil
+	case map[string]interface{}:
+		for key, val := range v {
+			if err := s.set(key, val); err != nil {
+				return nil, fmt.Errorf("%q: %w", key, err)
+			}
+		}
+		return &s, nil
+	}
+	return nil, fmt.Errorf("schema must be an object or boolean")
+}
+
+func (s *schema) set(key string, val interface{}) (err error) {
+	switch key {
+	case "type":
+		switch val := val.(type) {
+		case string:
+			s.types = []string{val}
+		case []interface{}:
+			for _, t := range val {
+				t, ok := t.(string)
+				if !ok {
+					return fmt.Errorf("invalid type")
+				}
+				s.types = append(s.types, t)
+			}
+		default:
+			return fmt.Errorf("invalid type")
+		}
+		for _, t := range s.types {
+			switch t {
+			case "null", "boolean", "object", "array",
+				"number", "integer", "string":
+			default:
+				return fmt.Errorf("unknown type: %q", t)
+			}
+		}
+	case "enum":
+		enum, ok := val.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be an array")
+		}
+		s.enum = enum
+	case "const":
+		s.cnst = &val
+	case "properties":
+		props, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("must be an object")
+		}
+		s.properties = make(map[string]*schema, len(props))
+		for name, prop := range props {
+			if s.properties[name], err = newSchema(prop); err != nil {
+				return fmt.Errorf("%q: %w", name, err)
+			}
+		}
+	case "patternProperties":
+		props, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("must be an object")
+		}
+		s.patternProperties = make(map[*regexp.Regexp]*schema, len(props))
+		for pattern, prop := range props {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return err
+			}
+			if s.patternProperties[re], err = newSchema(prop); err != nil {
+				return fmt.Errorf("%q: %w", pattern, err)
+			}
+		}
+	case "additionalProperties":
+		s.additionalProperties, err = newSchema(val)
+	case "required":
+		required, ok := val.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be an array")
+		}
+		for _, name := range required {
+			name, ok := name.(string)
+			if !ok {
+				return fmt.Errorf("must be an array of strings")
+			}
+			s.required = append(s.required, name)
+		}
+	case "minProperties":
+		s.minProperties, err = schemaInt(val)
+	case "maxProperties":
+		s.maxProperties, err = schemaInt(val)
+	case "items":
+		s.items, err = newSchema(val)
+	case "minItems":
+		s.minItems, err = schemaInt(val)
+	case "maxItems":
+		s.maxItems, err = schemaInt(val)
+	case "uniqueItems":
+		unique, ok := val.(bool)
+		if !ok {
+			return fmt.Errorf("must be a boolean")
+		}
+		s.uniqueItems = unique
+	case "minLength":
+		s.minLength, err = schemaInt(val)
+	case "maxLength":
+		s.maxLength, err = schemaInt(val)
+	case "pattern":
+		pattern, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		s.pattern, err = regexp.Compile(pattern)
+	case "minimum":
+		s.minimum, err = schemaNumber(val)
+	case "maximum":
+		s.maximum, err = schemaNumber(val)
+	case "exclusiveMinimum":
+		s.exclusiveMinimum, err = schemaNumber(val)
+	case "exclusiveMaximum":
+		s.exclusiveMaximum, err = schemaNumber(val)
+	case "multipleOf":
+		if s.multipleOf, err = schemaNumber(val); err == nil &&
+			*s.multipleOf <= 0 {
+			return fmt.Errorf("must be greater than 0")
+		}
+	case "allOf":
+		s.allOf, err = schemaList(val)
+	case "anyOf":
+		s.anyOf, err = schemaList(val)
+	case "oneOf":
+		s.oneOf, err = schemaList(val)
+	case "not":
+		s.not, err = newSchema(val)
+	default:
+		if _, ok := annotations[key]; !ok {
+			return fmt.Errorf("unsupported keyword")
+		}
+	}
+	return err
+}
+
+func schemaInt(val interface{}) (*int, error) {
+	n, ok := val.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("must be an integer")
+	}
+	i, err := n.Int64()
+	if err != nil || i < 0 {
+		return nil, fmt.Errorf("must be a non-negative integer")
+	}
+	v := int(i)
+	return &v, nil
+}
+
+func schemaNumber(val interface{}) (*float64, error) {
+	n, ok := val.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("must be a number")
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func schemaList(val interface{}) ([]*schema, error) {
+	list, ok := val.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("must be a non-empty array")
+	}
+	schemas := make([]*schema, len(list))
+	for i, v := range list {
+		var err error
+		if schemas[i], err = newSchema(v); err != nil {
+			return nil, fmt.Errorf("%v: %w", i, err)
+		}
+	}
+	return schemas, nil
+}
+
+// validate returns an error describing the first violation of s by v, which
+// is located at the JSON Pointer path.
+func (s *schema) validate(path string, v interface{}) error {
+	if s.boolean != nil {
+		if !*s.boolean {
+			return schemaError(path, "not allowed")
+		}
+		return nil
+	}
+
+	if len(s.types) > 0 {
+		t := jsonType(v)
+		ok := false
+		for _, st := range s.types {
+			if st == t || (st == "number" && t == "integer") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return schemaError(path, "expected type %v, got %v",
+				s.types, t)
+		}
+	}
+
+	if s.enum != nil {
+		ok := false
+		for _, e := range s.enum {
+			if jsonEqual(e, v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return schemaError(path, "not one of the enumerated values")
+		}
+	}
+
+	if s.cnst != nil && !jsonEqual(*s.cnst, v) {
+		return schemaError(path, "not equal to the constant value")
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if err := s.validateObject(path, v); err != nil {
+			return err
+		}
+	case []interface{}:
+		if err := s.validateArray(path, v); err != nil {
+			return err
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			return schemaError(path, "shorter than %v", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			return schemaError(path, "longer than %v", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return schemaError(path, "does not match pattern %q",
+				s.pattern)
+		}
+	case json.Number:
+		if err := s.validateNumber(path, v); err != nil {
+			return err
+		}
+	}
+
+	for _, sub := range s.allOf {
+		if err := sub.validate(path, v); err != nil {
+			return err
+		}
+	}
+	if s.anyOf != nil {
+		ok := false
+		for _, sub := range s.anyOf {
+			if sub.validate(path, v) == nil {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return schemaError(path, `does not match "anyOf"`)
+		}
+	}
+	if s.oneOf != nil {
+		matches := 0
+		for _, sub := range s.oneOf {
+			if sub.validate(path, v) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return schemaError(path,
+				`matches %v schemas in "oneOf"`, matches)
+		}
+	}
+	if s.not != nil && s.not.validate(path, v) == nil {
+		return schemaError(path, `matches "not"`)
+	}
+
+	return nil
+}
+
+func (s *schema) validateObject(path string, obj map[string]interface{}) error {
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			return schemaError(path, "missing required property %q",
+				name)
+		}
+	}
+	if s.minProperties != nil && len(obj) < *s.minProperties {
+		return schemaError(path, "fewer than %v properties",
+			*s.minProperties)
+	}
+	if s.maxProperties != nil && len(obj) > *s.maxProperties {
+		return schemaError(path, "more than %v properties",
+			*s.maxProperties)
+	}
+
+	// Validate in a deterministic order so that errors are reproducible.
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		val := obj[name]
+		propPath := path + "/" + name
+		matched := false
+		if prop, ok := s.properties[name]; ok {
+			matched = true
+			if err := prop.validate(propPath, val); err != nil {
+				return err
+			}
+		}
+		for re, prop := range s.patternProperties {
+			if !re.MatchString(name) {
+				continue
+			}
+			matched = true
+			if err := prop.validate(propPath, val); err != nil {
+				return err
+			}
+		}
+		if !matched && s.additionalProperties != nil {
+			if err := s.additionalProperties.validate(
+				propPath, val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *schema) validateArray(path string, arr []interface{}) error {
+	if s.minItems != nil && len(arr) < *s.minItems {
+		return schemaError(path, "fewer than %v items", *s.minItems)
+	}
+	if s.maxItems != nil && len(arr) > *s.maxItems {
+		return schemaError(path, "more than %v items", *s.maxItems)
+	}
+	if s.uniqueItems {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if jsonEqual(arr[i], arr[j]) {
+					return schemaError(path,
+						"items %v and %v are equal", i, j)
+				}
+			}
+		}
+	}
+	if s.items != nil {
+		for i, item := range arr {
+			if err := s.items.validate(
+				fmt.Sprintf("%v/%v", path, i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *schema) validateNumber(path string, n json.Number) error {
+	f, err := n.Float64()
+	if err != nil {
+		return schemaError(path, "invalid number")
+	}
+	if s.minimum != nil && f < *s.minimum {
+		return schemaError(path, "less than %v", *s.minimum)
+	}
+	if s.maximum != nil && f > *s.maximum {
+		return schemaError(path, "greater than %v", *s.maximum)
+	}
+	if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+		return schemaError(path, "not greater than %v",
+			*s.exclusiveMinimum)
+	}
+	if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+		return schemaError(path, "not less than %v",
+			*s.exclusiveMaximum)
+	}
+	if s.multipleOf != nil {
+		q := f / *s.multipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			return schemaError(path, "not a multiple of %v",
+				*s.multipleOf)
+		}
+	}
+	return nil
+}
+
+func schemaError(path, format string, args ...interface{}) error {
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Errorf("%v: %v", path, fmt.Sprintf(format, args...))
+}
+
+// decodeJSON unmarshals data into an interface{} using json.Number for all
+// numbers.
+func decodeJSON(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("invalid data after top-level value")
+	}
+	return v, nil
+}
+
+// jsonType returns the JSON Schema type name of v.
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// jsonEqual compares two decoded JSON values, treating numbers of equal value
+// as equal regardless of their representation.
+func jsonEqual(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		return aerr == nil && berr == nil && af == bf
+	}
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !jsonEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,86 @@
+package datastore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var jsonSchemaTests = []struct {
+	Name    string
+	Schema  string
+	Valid   []string
+	Invalid []string
+}{{
+	Name: "object",
+	Schema: `{"type": "object",
+		"properties": {
+			"filename": {"type": "string", "minLength": 1},
+			"version": {"type": "integer", "minimum": 1}},
+		"required": ["filename"],
+		"additionalProperties": false}`,
+	Valid: []string{`{"filename":"a.txt"}`, `{"filename":"a","version":2}`},
+	Invalid: []string{``, `null`, `[]`, `{}`, `{"filename":""}`,
+		`{"filename":"a","version":1.5}`,
+		`{"filename":"a","version":0}`,
+		`{"filename":"a","extra":true}`},
+}, {
+	Name:    "enum",
+	Schema:  `{"enum": ["a", 1, null]}`,
+	Valid:   []string{`"a"`, `1`, `1.0`, `null`, ``},
+	Invalid: []string{`"b"`, `2`, `{}`},
+}, {
+	Name: "array",
+	Schema: `{"type": "array", "items": {"type": "string",
+		"pattern": "^[a-z]+$"}, "minItems": 1, "uniqueItems": true}`,
+	Valid:   []string{`["a"]`, `["a","b"]`},
+	Invalid: []string{`[]`, `["a","a"]`, `["A"]`, `[1]`},
+}, {
+	Name:    "oneOf",
+	Schema:  `{"oneOf": [{"type": "integer"}, {"type": "number", "maximum": 5}]}`,
+	Valid:   []string{`1.5`, `6`},
+	Invalid: []string{`1`, `5.5`, `"1"`},
+}, {
+	Name:    "not",
+	Schema:  `{"not": {"type": "null"}}`,
+	Valid:   []string{`{}`, `0`},
+	Invalid: []string{`null`, ``},
+}}
+
+func TestJSONSchema(t *testing.T) {
+	for _, test := range jsonSchemaTests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			decode, err := JSONSchema([]byte(test.Schema))
+			require.NoError(t, err)
+			for _, v := range test.Valid {
+				_, err := decode(rawMessage(v))
+				assert.NoError(t, err, v)
+			}
+			for _, v := range test.Invalid {
+				_, err := decode(rawMessage(v))
+				assert.Error(t, err, v)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaInvalid(t *testing.T) {
+	for _, schema := range []string{
+		`[]`, `{"type": "float"}`, `{"$ref": "#/definitions/a"}`,
+		`{"pattern": "("}`, `{"minItems": -1}`, `{"allOf": []}`,
+	} {
+		_, err := JSONSchema([]byte(schema))
+		assert.Error(t, err, schema)
+	}
+}
+
+// rawMessage returns nil for an empty string to represent a missing value.
+func rawMessage(v string) json.RawMessage {
+	if len(v) == 0 {
+		return nil
+	}
+	return json.RawMessage(v)
+}
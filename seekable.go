@@ -0,0 +1,120 @@
+package datastore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/factom"
+)
+
+// FormatGzipSeekable is the Compression Format for data compressed as a
+// sequence of independent gzip members, called frames, which allows
+// DownloadRange to download and decompress only the frames that it needs.
+//
+// Every frame, except the last, holds exactly Compression.FrameSize bytes of
+// uncompressed data, and Compression.Frames lists the compressed size of each
+// frame in order. Since the frames are concatenated, the data can also be
+// decompressed as a single multistream gzip file.
+const FormatGzipSeekable = "gzip-seekable"
+
+const (
+	// DefaultFrameSize is the uncompressed size of each frame used by
+	// Compress for FormatGzipSeekable, unless the data is so large that
+	// it would require more than MaxFrames frames.
+	DefaultFrameSize = 64 * factom.EntryMaxDataLen
+
+	// MaxFrames limits the number of frames used by Compress, so that the
+	// frame index fits comfortably within the First Entry.
+	MaxFrames = 256
+)
+
+// CompressSeekable compresses data using FormatGzipSeekable with the given
+// uncompressed frameSize.
+func CompressSeekable(data []byte, frameSize uint64) (
+	[]byte, *Compression, error) {
+	if frameSize == 0 {
+		return nil, nil, fmt.Errorf("invalid frame size")
+	}
+	cmp := Compression{Format: FormatGzipSeekable, FrameSize: frameSize}
+	cData := bytes.NewBuffer(make([]byte, 0, len(data)))
+	w := gzip.NewWriter(cData)
+	for len(data) > 0 {
+		frame := data
+		if uint64(len(frame)) > frameSize {
+			frame = frame[:frameSize]
+		}
+		data = data[len(frame):]
+
+		start := cData.Len()
+		w.Reset(cData)
+		if _, err := w.Write(frame); err != nil {
+			return nil, nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, nil, err
+		}
+		cmp.Frames = append(cmp.Frames, uint64(cData.Len()-start))
+	}
+	cmp.Size = uint64(cData.Len())
+	return cData.Bytes(), &cmp, nil
+}
+
+// seekableFrameSize returns the frame size used by Compress for size bytes of
+// data.
+func seekableFrameSize(size uint64) uint64 {
+	frameSize := uint64(DefaultFrameSize)
+	if size <= frameSize*MaxFrames {
+		return frameSize
+	}
+	// Round up to a multiple of the Data Block size.
+	frameSize = (size + MaxFrames - 1) / MaxFrames
+	if r := frameSize % factom.EntryMaxDataLen; r > 0 {
+		frameSize += factom.EntryMaxDataLen - r
+	}
+	return frameSize
+}
+
+// isSeekable returns true if cmp uses FormatGzipSeekable.
+func (cmp Compression) isSeekable() bool {
+	return strings.ToLower(cmp.Format) == FormatGzipSeekable
+}
+
+// validateFrames validates the frame index of cmp for size bytes of
+// uncompressed data.
+func (cmp Compression) validateFrames(size uint64) error {
+	if !cmp.isSeekable() {
+		if cmp.FrameSize != 0 || len(cmp.Frames) > 0 {
+			return fmt.Errorf(`unexpected "frames"`)
+		}
+		return nil
+	}
+	if cmp.FrameSize == 0 {
+		return fmt.Errorf(`invalid "frame-size"`)
+	}
+	nFrames := size / cmp.FrameSize
+	if size%cmp.FrameSize > 0 {
+		nFrames++
+	}
+	if uint64(len(cmp.Frames)) != nFrames {
+		return fmt.Errorf(`invalid len("frames")`)
+	}
+	var total uint64
+	for _, frame := range cmp.Frames {
+		if frame == 0 || frame > cmp.Size-total {
+			return fmt.Errorf(`invalid "frames"`)
+		}
+		total += frame
+	}
+	if total != cmp.Size {
+		return fmt.Errorf(`invalid "frames"`)
+	}
+	return nil
+}
+
+// Seekable returns true if DownloadRange supports m, which is the case for
+// uncompressed data and FormatGzipSeekable.
+func (m Metadata) Seekable() bool {
+	return m.Compression == nil || m.Compression.isSeekable()
+}
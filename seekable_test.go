@@ -0,0 +1,121 @@
+package datastore
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+func TestSeekable(t *testing.T) {
+	require := require.New(t)
+
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	// Compressible data spanning many frames.
+	const frameSize = 4 * factom.EntryMaxDataLen
+	data := make([]byte, 10*frameSize+123)
+	for i := range data {
+		data[i] = byte('a' + rand.Intn(4))
+	}
+	dataHash := ComputeDataHash(data)
+
+	cData, compression, err := CompressSeekable(data, frameSize)
+	require.NoError(err)
+	require.Len(compression.Frames, 11)
+	assert.Less(t, len(cData), len(data))
+
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	_, _, _, _, reveals, _, err := Generate(nil, nil, es,
+		bytes.NewReader(cData), compression, uint64(len(data)),
+		&dataHash, nil)
+	require.NoError(err)
+	factomd.AddEntries(reveals...)
+
+	var first factom.Entry
+	require.NoError(first.UnmarshalBinary(reveals[0]))
+	m, err := ParseOptions{Strict: true}.ParseEntry(first)
+	require.NoError(err)
+	require.True(m.Seekable())
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(m.Download(nil, c, buf))
+	assert.Equal(t, data, buf.Bytes())
+
+	for _, rng := range []struct{ Offset, Length int }{
+		{0, 1},
+		{0, len(data)},
+		{frameSize - 1, 2},
+		{3 * frameSize, frameSize},
+		{5*frameSize + 7, 100},
+		{len(data) - 10, 10},
+	} {
+		buf.Reset()
+		calls := factomd.Calls("raw-data")
+		require.NoError(m.DownloadRange(nil, c, buf,
+			uint64(rng.Offset), uint64(rng.Length)), rng)
+		assert.Equal(t, data[rng.Offset:rng.Offset+rng.Length],
+			buf.Bytes(), rng)
+		if rng.Length < frameSize {
+			// Only a fraction of the Data Blocks are downloaded.
+			assert.Less(t, factomd.Calls("raw-data")-calls,
+				len(reveals)/3, rng)
+		}
+	}
+
+	// Each frame in a range must decompress to exactly the frame size,
+	// so a shifted frame index is detected.
+	shifted := m
+	shiftedCmp := *m.Compression
+	shiftedCmp.Frames = append([]uint64{}, m.Compression.Frames...)
+	shiftedCmp.Frames[1]++
+	shiftedCmp.Frames[2]--
+	shifted.Compression = &shiftedCmp
+	buf.Reset()
+	assert.Error(t, shifted.DownloadRange(nil, c, buf, 2*frameSize, 10))
+	assert.Error(t, shifted.DownloadRange(nil, c, buf, frameSize, 10))
+	buf.Reset()
+	require.NoError(shifted.DownloadRange(nil, c, buf, 3*frameSize, 10))
+	assert.Equal(t, data[3*frameSize:3*frameSize+10], buf.Bytes())
+
+	// The frame index is validated.
+	for _, cmp := range []string{
+		`{"format":"gzip-seekable","size":10}`,
+		`{"format":"gzip-seekable","size":10,"frame-size":1,"frames":[10]}`,
+		`{"format":"gzip-seekable","size":10,"frame-size":100,"frames":[5,5]}`,
+		`{"format":"gzip-seekable","size":10,"frame-size":100,"frames":[9]}`,
+		`{"format":"gzip","size":10,"frame-size":100,"frames":[10]}`,
+	} {
+		e := newFirstEntry(`{"data-store":"1.0","size":100,` +
+			dbiStart + `,"compression":` + cmp + `}`)
+		_, err := ParseEntry(e)
+		assert.Error(t, err, cmp)
+	}
+	e := newFirstEntry(`{"data-store":"1.0","size":100,` + dbiStart +
+		`,"compression":{"format":"gzip-seekable","size":10,` +
+		`"frame-size":60,"frames":[6,4]}}`)
+	_, err = ParseEntry(e)
+	assert.NoError(t, err)
+}
+
+func TestCompressSeekable(t *testing.T) {
+	data := make([]byte, 100*DefaultFrameSize)
+	cData, compression, err := Compress(FormatGzipSeekable, data)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(len(cData)), compression.Size)
+	assert.Equal(t, uint64(DefaultFrameSize), compression.FrameSize)
+	assert.Len(t, compression.Frames, 100)
+
+	size := uint64(MaxFrames*DefaultFrameSize + 1)
+	frameSize := seekableFrameSize(size)
+	assert.Zero(t, frameSize%factom.EntryMaxDataLen)
+	assert.LessOrEqual(t, (size+frameSize-1)/frameSize, uint64(MaxFrames))
+}
@@ -0,0 +1,166 @@
+package datastore
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"golang.org/x/sync/errgroup"
+)
+
+// DownloadTo downloads the Data Store described by m and writes the data to w,
+// starting at offset zero.
+//
+// For Data Stores without compression, if w also implements io.ReaderAt, such
+// as an *os.File, each Data Block is written directly to its offset in w as
+// soon as it is downloaded and verified, and then the sha256d data hash is
+// verified by reading the data back from w. Only a bounded number of Data
+// Blocks are held in memory at once, regardless of the size of the data.
+//
+// Otherwise, this is equivalent to Download, with the data written to w
+// sequentially.
+//
+// If an error is returned, w may hold partial or invalid data.
+func (opts DownloadOptions) DownloadTo(ctx context.Context, c *factom.Client,
+	m Metadata, w io.WriterAt) error {
+	r, ok := w.(io.ReaderAt)
+	if m.Compression != nil || !ok {
+		return opts.Download(ctx, c, m, &offsetWriter{w: w})
+	}
+
+	_, totalDBCount := m.EntryCounts()
+	if err := opts.writeDataBlocks(ctx, c, m, w, totalDBCount, m.Size,
+		func(ctx context.Context, fn func(int, factom.Bytes32) error) error {
+			return opts.traverseDBI(ctx, c, m, totalDBCount, fn)
+		}, nil); err != nil {
+		return err
+	}
+
+	// Verify the data hash.
+	hash := sha256.New()
+	if _, err := io.Copy(hash,
+		io.NewSectionReader(r, 0, int64(m.Size))); err != nil {
+		return err
+	}
+	if *m.DataHash != sha256.Sum256(hash.Sum(nil)) {
+		return fmt.Errorf("invalid data hash")
+	}
+	return nil
+}
+
+// writeDataBlocks downloads the n Data Block Entries of m, totalling nBytes,
+// that each passes to its fn, and writes each to its offset within the on
+// chain data in w. Then written, if not nil, is called with the index of the
+// Data Block.
+//
+// At most 2*Workers*BatchSize Data Block buffers are allocated, and each is
+// reused once its Data Block is written.
+func (opts DownloadOptions) writeDataBlocks(ctx context.Context,
+	c *factom.Client, m Metadata, w io.WriterAt, n int, nBytes uint64,
+	each func(context.Context, func(int, factom.Bytes32) error) error,
+	written func(i int) error) error {
+
+	// Get the on-chain size.
+	size := m.Size
+	if m.Compression != nil {
+		size = m.Compression.Size
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	limit := 2 * workers * batchSize
+
+	// bufs holds the buffers of written Data Blocks for reuse.
+	bufs := make(chan []byte, limit)
+	var allocated int
+
+	dbEs := make(chan dataBlock, workers*batchSize)
+
+	// The DBI and Data Blocks are downloaded concurrently.
+	opts.Progress = opts.Progress.serialized()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, ctx := errgroup.WithContext(ctx)
+	opts.getDataBlocks(g, ctx, c, dbEs, newProgress(opts.Progress,
+		StageDownload, n, nBytes),
+		func(dbE dataBlock) error {
+			_, err := w.WriteAt(dbE.Content,
+				int64(dbE.I)*factom.EntryMaxDataLen)
+			// Only the last Data Block, which is sent last, has
+			// a shorter buffer.
+			bufs <- dbE.Content[:cap(dbE.Content)]
+			if err != nil || written == nil {
+				return err
+			}
+			return written(dbE.I)
+		})
+
+	err := each(ctx, func(i int, dbEHash factom.Bytes32) error {
+		// Reuse a buffer, if one is available, otherwise
+		// allocate one, unless the limit has been reached.
+		var buf []byte
+		select {
+		case buf = <-bufs:
+		default:
+			if allocated < limit {
+				buf = make([]byte, factom.EntryMaxDataLen)
+				allocated++
+				break
+			}
+			select {
+			case buf = <-bufs:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		// Only the last Data Block may be smaller.
+		dbELen := size - uint64(i)*factom.EntryMaxDataLen
+		if dbELen > factom.EntryMaxDataLen {
+			dbELen = factom.EntryMaxDataLen
+		}
+		dbE := dataBlock{I: i}
+		dbE.Hash = &dbEHash
+		dbE.Content = buf[:0:dbELen]
+
+		select {
+		case dbEs <- dbE:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(dbEs)
+
+	// Prefer any error from the workers, which cancels ctx and so may
+	// cause the DBI traversal to fail as well.
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return err
+}
+
+// offsetWriter writes sequentially to an io.WriterAt.
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
@@ -0,0 +1,83 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fds/internal/factomdtest"
+)
+
+// writerAt is an io.WriterAt that does not implement io.ReaderAt.
+type writerAt []byte
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(*w) {
+		*w = append(*w, make([]byte, end-len(*w))...)
+	}
+	return copy((*w)[off:], p), nil
+}
+
+func TestDownloadTo(t *testing.T) {
+	factomd := factomdtest.NewServer()
+	defer factomd.Close()
+	c := factomd.Client()
+
+	for _, test := range []struct {
+		Name   string
+		Size   int
+		Format string
+	}{
+		{"uncompressed", 30*factom.EntryMaxDataLen + 100, ""},
+		{"single block", 100, ""},
+		{"gzip", 3*factom.EntryMaxDataLen + 100, "gzip"},
+	} {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			require := require.New(t)
+			data, m, reveals := generateTestStore(t, test.Size,
+				test.Format)
+			factomd.AddEntries(reveals...)
+
+			f, err := ioutil.TempFile("", "fds-test")
+			require.NoError(err)
+			defer os.Remove(f.Name())
+			defer f.Close()
+
+			opts := DownloadOptions{Workers: 2, BatchSize: 2}
+			require.NoError(opts.DownloadTo(nil, c, m, f))
+			written, err := ioutil.ReadFile(f.Name())
+			require.NoError(err)
+			require.Equal(data, written)
+
+			var w writerAt
+			require.NoError(opts.DownloadTo(nil, c, m, &w))
+			require.Equal(data, []byte(w))
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, m, reveals := generateTestStore(t, 5*factom.EntryMaxDataLen, "")
+		factomd.AddEntries(reveals...)
+
+		f, err := ioutil.TempFile("", "fds-test")
+		require.NoError(t, err)
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		wrong := *m.DataHash
+		wrong[0]++
+		invalid := m
+		invalid.DataHash = &wrong
+		assert.EqualError(t, DownloadOptions{}.DownloadTo(nil, c, invalid, f),
+			"invalid data hash")
+
+		// A missing Data Block stops the download.
+		factomd.RemoveEntries(factom.ComputeEntryHash(reveals[3]))
+		assert.Error(t, DownloadOptions{Workers: 1}.DownloadTo(nil, c, m, f))
+	})
+}